@@ -0,0 +1,24 @@
+package cmd
+
+import "runtime"
+
+// Limits how many git processes and API calls run at the same time across all stages,
+// so that repositories with thousands of branches don't exhaust processes or file descriptors.
+// A pool can be shared by the repositories cleaned up at the same time to keep the limit across all of them.
+type WorkerPool chan struct{}
+
+func NewWorkerPool(jobs int) WorkerPool {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	return make(WorkerPool, jobs)
+}
+
+// Blocks until a worker is available. Callers must call release when the work is done.
+func (p WorkerPool) acquire() {
+	p <- struct{}{}
+}
+
+func (p WorkerPool) release() {
+	<-p
+}
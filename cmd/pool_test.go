@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_WorkerPool(t *testing.T) {
+	t.Run("defaults to the number of CPUs", func(t *testing.T) {
+		assert.Equal(t, runtime.NumCPU(), cap(NewWorkerPool(0)))
+	})
+
+	t.Run("limits the number of running workers", func(t *testing.T) {
+		pool := NewWorkerPool(2)
+		var running, maxRunning atomic.Int32
+		var wg sync.WaitGroup
+
+		for range 10 {
+			wg.Add(1)
+			pool.acquire()
+			go func() {
+				defer wg.Done()
+				defer pool.release()
+				n := running.Add(1)
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				running.Add(-1)
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+	})
+}
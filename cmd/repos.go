@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/seachicken/gh-poi/conn"
+	"github.com/seachicken/gh-poi/shared"
+)
+
+// Returns the git repositories under the root directory, including bare repositories.
+// The search does not descend into repositories, and linked worktrees and submodules
+// (whose `.git` is a file) are skipped since they are cleaned through the repository they belong to.
+func FindRepositories(root string) ([]string, error) {
+	results := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped rather than failing the whole search
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return fs.SkipDir
+		}
+
+		if info, err := os.Stat(filepath.Join(path, ".git")); err == nil {
+			if info.IsDir() {
+				results = append(results, path)
+			}
+			return fs.SkipDir
+		}
+		if isBareRepository(path) {
+			results = append(results, path)
+			return fs.SkipDir
+		}
+		return nil
+	})
+	return results, err
+}
+
+// Reads repository paths separated by newlines, e.g. the output of `ghq list --full-path`.
+func ReadRepositories(r io.Reader) ([]string, error) {
+	results := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(scanner.Text()); path != "" {
+			results = append(results, path)
+		}
+	}
+	return results, scanner.Err()
+}
+
+// Returns the directories of the initialized submodules, including nested ones, joined to the repository directory.
+// Uninitialized submodules have no clone to clean up, so they are skipped.
+func GetSubmoduleDirs(ctx context.Context, connection shared.Connection, dir string) ([]string, error) {
+	submodules, err := conn.GetSubmodules(ctx, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []string{}
+	for _, submodule := range submodules {
+		if submodule.IsInitialized {
+			results = append(results, filepath.Join(dir, submodule.Path))
+		}
+	}
+	return results, nil
+}
+
+func isBareRepository(path string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
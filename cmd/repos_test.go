@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seachicken/gh-poi/conn"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func Test_FindRepositories(t *testing.T) {
+	root := t.TempDir()
+	mkdirs := func(paths ...string) {
+		for _, path := range paths {
+			if err := os.MkdirAll(filepath.Join(root, path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	mkdirs(
+		"github.com/owner/repo1/.git",
+		"github.com/owner/repo1/nested/.git",
+		"github.com/owner/repo2/.git",
+		"github.com/owner/bare.git/objects",
+		"github.com/owner/bare.git/refs",
+		"github.com/owner/worktree",
+		"notRepo/src",
+	)
+	os.WriteFile(filepath.Join(root, "github.com/owner/bare.git/HEAD"), []byte("ref: refs/heads/main\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "github.com/owner/worktree/.git"), []byte("gitdir: ../bare.git/worktrees/worktree\n"), 0o644)
+
+	actual, err := FindRepositories(root)
+
+	assert.Nil(t, err)
+	assert.Equal(t,
+		[]string{
+			filepath.Join(root, "github.com/owner/bare.git"),
+			filepath.Join(root, "github.com/owner/repo1"),
+			filepath.Join(root, "github.com/owner/repo2"),
+		},
+		actual,
+	)
+}
+
+func Test_ReadRepositories(t *testing.T) {
+	actual, err := ReadRepositories(strings.NewReader("/src/repo1\n\n/src/repo2\n"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/src/repo1", "/src/repo2"}, actual)
+}
+
+func Test_GetSubmoduleDirsSkipsUninitializedSubmodules(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := conn.Setup(ctrl).
+		GetSubmodules([]conn.SubmoduleStub{
+			{Path: "", Filename: "lib"},
+		}, nil, nil)
+
+	actual, err := GetSubmoduleDirs(context.Background(), s.Conn, "/src/repo")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join("/src/repo", "libs/lib")}, actual)
+}
@@ -0,0 +1,152 @@
+package restack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/seachicken/gh-poi/cmd"
+	"github.com/seachicken/gh-poi/conn"
+	"github.com/seachicken/gh-poi/shared"
+)
+
+type Result struct {
+	BranchName string
+	Onto       string
+}
+
+type task struct {
+	branchName string
+	upstream   string
+	onto       string
+}
+
+// Rebases the branches stacked on merged branches onto the default branch of the first remote,
+// so that the merged branches are no longer kept and can be deleted.
+// Branches whose local base branch was merged and deleted are rebased the same way.
+// Branches stacked on a restacked branch are rebased onto its new commits in turn.
+// When a rebase stops on conflicts, it is aborted and the restack stops there.
+func RestackBranches(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, state shared.PullRequestState, connection shared.Connection, dryRun bool) (results []Result, err error) {
+	results = []Result{}
+
+	headIndex := slices.IndexFunc(branches, func(b shared.Branch) bool { return b.Head })
+	if headIndex >= 0 && branches[headIndex].HasTrackedChanges {
+		return results, errors.New("cannot restack with uncommitted changes")
+	}
+	// A stopped operation is never touched, since aborting it would lose the work in progress
+	operations, err := conn.GetOperations(ctx, connection, "")
+	if err != nil {
+		return results, err
+	}
+	if len(operations) > 0 {
+		return results, fmt.Errorf("cannot restack because a %s is in progress; finish or abort it, then run again", operations[0].Kind)
+	}
+
+	defaultBranchName, err := cmd.GetDefaultBranchName(ctx, remotes, connection)
+	if err != nil {
+		return results, err
+	}
+	// The local default branch may not have the merged changes yet
+	onto := remotes[0].Name + "/" + defaultBranchName
+
+	branchMap := make(map[string]shared.Branch)
+	for _, branch := range branches {
+		branchMap[branch.Name] = branch
+	}
+
+	queue := []task{}
+	for _, branch := range branches {
+		if branch.DeletedBase != nil {
+			queue = append(queue, task{branchName: branch.Name, upstream: branch.DeletedBase.Oid, onto: onto})
+			continue
+		}
+		if !cmd.IsMergedBase(branch, state) {
+			continue
+		}
+		for _, name := range cmd.GetDirectChildNames(branch, branches) {
+			queue = append(queue, task{branchName: name, upstream: branch.Name, onto: onto})
+		}
+	}
+
+	if !dryRun && len(queue) > 0 {
+		if _, err := connection.FetchBranch(ctx, remotes[0].Name, defaultBranchName); err != nil {
+			return results, err
+		}
+	}
+
+	// Rebasing checks out each branch, so the branch checked out before is restored, also when a rebase stops
+	rebased := false
+	defer func() {
+		if !rebased || headIndex < 0 || branches[headIndex].IsDetached() {
+			return
+		}
+		if _, checkoutErr := connection.CheckoutBranch(ctx, branches[headIndex].Name, false); checkoutErr != nil && err == nil {
+			err = checkoutErr
+		}
+	}()
+
+	visited := make(map[string]bool)
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		branch, ok := branchMap[t.branchName]
+		if !ok || visited[t.branchName] {
+			continue
+		}
+		visited[t.branchName] = true
+
+		// A merged branch is left as is, and the branches on it are moved instead
+		if cmd.IsMergedBase(branch, state) {
+			for _, name := range cmd.GetDirectChildNames(branch, branches) {
+				queue = append(queue, task{branchName: name, upstream: branch.Name, onto: t.onto})
+			}
+			continue
+		}
+		if branch.Operation != "" {
+			fmt.Fprintf(os.Stderr, "warning: skipped '%s': a %s is in progress\n", branch.Name, branch.Operation)
+			continue
+		}
+		if branch.Worktree != nil && !branch.Head {
+			fmt.Fprintf(os.Stderr, "warning: skipped '%s': checked out in worktree %s\n", branch.Name, branch.Worktree.Path)
+			continue
+		}
+
+		oids, err := connection.GetLog(ctx, branch.Name, 1)
+		if err != nil {
+			return results, err
+		}
+		oldHeadOid := strings.TrimSpace(oids)
+
+		if !dryRun {
+			rebased = true
+			if _, err := connection.RebaseBranch(ctx, branch.Name, t.upstream, t.onto); err != nil {
+				// No operation was in progress before, so a stopped rebase is the one started here
+				if operations, _ := conn.GetOperations(ctx, connection, ""); slices.ContainsFunc(operations, func(o shared.Operation) bool {
+					return o.Kind == "rebase"
+				}) {
+					connection.AbortRebase(ctx)
+				}
+				return results, fmt.Errorf(
+					"conflicts while restacking '%s' onto '%s', resolve them with 'git rebase --onto %s %s %s': %w",
+					branch.Name, t.onto, t.onto, t.upstream, branch.Name, err)
+			}
+			// A branch tracking its local base keeps tracking it after the base changes
+			remoteConfig, _ := connection.GetConfig(ctx, fmt.Sprintf("branch.%s.remote", branch.Name))
+			if strings.TrimSpace(remoteConfig) == "." {
+				if _, err := connection.SetUpstream(ctx, branch.Name, t.onto); err != nil {
+					return results, err
+				}
+			}
+		}
+		results = append(results, Result{BranchName: branch.Name, Onto: t.onto})
+
+		for _, name := range cmd.GetDirectChildNames(branch, branches) {
+			queue = append(queue, task{branchName: name, upstream: oldHeadOid, onto: branch.Name})
+		}
+	}
+
+	return results, nil
+}
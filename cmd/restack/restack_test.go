@@ -0,0 +1,222 @@
+package restack
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/seachicken/gh-poi/conn"
+	"github.com/seachicken/gh-poi/shared"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+var remotes = []shared.Remote{{Name: "origin", Hostname: "github.com", RepoName: "owner/repo"}}
+
+func setupDefault(s *conn.Stub) *conn.Stub {
+	return s.
+		GetRepoNames([]conn.RepoNamesStub{
+			{RepoName: "owner/repo", Filename: "origin"},
+		}, nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil)
+}
+
+func mergedBranch(name string, stackedBranchNames ...string) shared.Branch {
+	return shared.Branch{
+		Name:               name,
+		Commits:            []string{name},
+		PullRequests:       []shared.PullRequest{{State: shared.Merged, Commits: []string{name}}},
+		StackedBranchNames: stackedBranchNames,
+	}
+}
+
+/*
+// main   : A---B (issue1 squash merged)
+//           \
+// issue1 :   C (merged)
+//             \
+// issue2 :     D
+//               \
+// issue3 :       E
+*/
+func Test_RestackBranches(t *testing.T) {
+	branches := []shared.Branch{
+		mergedBranch("issue1", "issue2", "issue3"),
+		{Name: "issue2", StackedBranchNames: []string{"issue3"}},
+		{Name: "issue3"},
+		{Head: true, Name: "main", IsDefault: true},
+	}
+
+	t.Run("rebases branches on merged branch onto default branch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl)).
+			GetConfig([]conn.ConfigStub{
+				{Key: "branch.issue2.remote", Filename: "local"},
+				{Key: "branch.issue3.remote", Filename: "local"},
+			}, nil, nil).
+			CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 1}))
+		gomock.InOrder(
+			s.Conn.EXPECT().FetchBranch(gomock.Any(), "origin", "main").Return("", nil),
+			s.Conn.EXPECT().GetLog(gomock.Any(), "issue2", 1).Return("D\n", nil),
+			s.Conn.EXPECT().RebaseBranch(gomock.Any(), "issue2", "issue1", "origin/main").Return("", nil),
+			s.Conn.EXPECT().SetUpstream(gomock.Any(), "issue2", "origin/main").Return("", nil),
+			s.Conn.EXPECT().GetLog(gomock.Any(), "issue3", 1).Return("E\n", nil),
+			s.Conn.EXPECT().RebaseBranch(gomock.Any(), "issue3", "D", "issue2").Return("", nil),
+			s.Conn.EXPECT().SetUpstream(gomock.Any(), "issue3", "issue2").Return("", nil),
+		)
+
+		actual, err := RestackBranches(context.Background(), remotes, branches, shared.Merged, s.Conn, false)
+
+		assert.Nil(t, err)
+		assert.Equal(t, []Result{
+			{BranchName: "issue2", Onto: "origin/main"},
+			{BranchName: "issue3", Onto: "issue2"},
+		}, actual)
+	})
+
+	t.Run("does not rebase in dry run", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl)).
+			FetchBranch(nil, conn.NewConf(&conn.Times{N: 0})).
+			RebaseBranch(nil, conn.NewConf(&conn.Times{N: 0})).
+			SetUpstream(nil, conn.NewConf(&conn.Times{N: 0})).
+			CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 0}))
+		s.Conn.EXPECT().GetLog(gomock.Any(), gomock.Any(), 1).Return("D\n", nil).AnyTimes()
+
+		actual, err := RestackBranches(context.Background(), remotes, branches, shared.Merged, s.Conn, true)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(actual))
+	})
+
+	t.Run("aborts and stops on conflicts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := conn.Setup(ctrl).
+			GetOperations([]conn.OperationStub{
+				{Path: "", Filename: "none"},
+			}, nil, conn.NewConf(&conn.Times{N: 1})).
+			GetOperations([]conn.OperationStub{
+				{Path: "", Filename: "rebaseIssue1"},
+			}, nil, conn.NewConf(&conn.Times{N: 1})).
+			GetRepoNames([]conn.RepoNamesStub{
+				{RepoName: "owner/repo", Filename: "origin"},
+			}, nil, nil).
+			FetchBranch(nil, nil).
+			RebaseBranch(errors.New("conflict"), conn.NewConf(&conn.Times{N: 1})).
+			AbortRebase(nil, conn.NewConf(&conn.Times{N: 1}))
+		s.Conn.EXPECT().GetLog(gomock.Any(), "issue2", 1).Return("D\n", nil)
+		s.Conn.EXPECT().CheckoutBranch(gomock.Any(), "main", false).Return("", nil)
+
+		actual, err := RestackBranches(context.Background(), remotes, branches, shared.Merged, s.Conn, false)
+
+		assert.ErrorContains(t, err, "git rebase --onto origin/main issue1 issue2")
+		assert.Equal(t, []Result{}, actual)
+	})
+
+	t.Run("does not abort when the rebase was not started", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl)).
+			FetchBranch(nil, nil).
+			RebaseBranch(errors.New("invalid upstream"), conn.NewConf(&conn.Times{N: 1})).
+			AbortRebase(nil, conn.NewConf(&conn.Times{N: 0})).
+			CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 1}))
+		s.Conn.EXPECT().GetLog(gomock.Any(), "issue2", 1).Return("D\n", nil)
+
+		_, err := RestackBranches(context.Background(), remotes, branches, shared.Merged, s.Conn, false)
+
+		assert.ErrorContains(t, err, "invalid upstream")
+	})
+
+	t.Run("refuses to run while an operation is in progress", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := conn.Setup(ctrl).
+			GetOperations([]conn.OperationStub{
+				{Path: "", Filename: "rebaseIssue1"},
+			}, nil, nil).
+			RebaseBranch(nil, conn.NewConf(&conn.Times{N: 0})).
+			AbortRebase(nil, conn.NewConf(&conn.Times{N: 0}))
+
+		actual, err := RestackBranches(context.Background(), remotes, branches, shared.Merged, s.Conn, false)
+
+		assert.ErrorContains(t, err, "a rebase is in progress")
+		assert.Equal(t, []Result{}, actual)
+	})
+}
+
+func Test_RestackBranchesSkipsBranchesWithOperation(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := setupDefault(conn.Setup(ctrl)).
+		FetchBranch(nil, nil).
+		RebaseBranch(nil, conn.NewConf(&conn.Times{N: 0})).
+		CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 0}))
+
+	actual, err := RestackBranches(context.Background(), remotes, []shared.Branch{
+		mergedBranch("issue1", "issue2"),
+		{Name: "issue2", Operation: "rebase", Worktree: &shared.Worktree{Path: "/path/to/worktree"}},
+		{Head: true, Name: "main", IsDefault: true},
+	}, shared.Merged, s.Conn, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Result{}, actual)
+}
+
+/*
+// main   : A---B (issue1 squash merged)
+//           \
+// issue1 :   C (merged and deleted)
+//             \
+// issue2 :     D
+*/
+func Test_RestackBranchesWithDeletedBase(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := setupDefault(conn.Setup(ctrl)).
+		GetConfig([]conn.ConfigStub{
+			{Key: "branch.issue2.remote", Filename: "local"},
+		}, nil, nil).
+		FetchBranch(nil, conn.NewConf(&conn.Times{N: 1})).
+		CheckoutBranch(nil, nil)
+	s.Conn.EXPECT().GetLog(gomock.Any(), "issue2", 1).Return("D\n", nil)
+	s.Conn.EXPECT().RebaseBranch(gomock.Any(), "issue2", "C", "origin/main").Return("", nil)
+	s.Conn.EXPECT().SetUpstream(gomock.Any(), "issue2", "origin/main").Return("", nil)
+
+	actual, err := RestackBranches(context.Background(), remotes, []shared.Branch{
+		{Name: "issue2", Commits: []string{"D", "C"}, DeletedBase: &shared.DeletedBase{Name: "issue1", Oid: "C"}},
+		{Head: true, Name: "main", IsDefault: true},
+	}, shared.Merged, s.Conn, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Result{{BranchName: "issue2", Onto: "origin/main"}}, actual)
+}
+
+func Test_RestackBranchesSkipsMergedBranchesInStack(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s := setupDefault(conn.Setup(ctrl)).
+		GetConfig([]conn.ConfigStub{
+			{Key: "branch.issue3.remote", Filename: "remote"},
+		}, nil, nil).
+		FetchBranch(nil, nil).
+		SetUpstream(nil, conn.NewConf(&conn.Times{N: 0})).
+		CheckoutBranch(nil, nil)
+	s.Conn.EXPECT().GetLog(gomock.Any(), "issue3", 1).Return("E\n", nil)
+	s.Conn.EXPECT().RebaseBranch(gomock.Any(), "issue3", "issue2", "origin/main").Return("", nil)
+
+	actual, err := RestackBranches(context.Background(), remotes, []shared.Branch{
+		mergedBranch("issue1", "issue2", "issue3"),
+		mergedBranch("issue2", "issue3"),
+		{Name: "issue3"},
+		{Head: true, Name: "main", IsDefault: true},
+	}, shared.Merged, s.Conn, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Result{{BranchName: "issue3", Onto: "origin/main"}}, actual)
+}
@@ -2,10 +2,11 @@ package cmd
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"regexp"
 	"slices"
 	"sort"
@@ -20,10 +21,46 @@ import (
 const (
 	github    = "github.com"
 	localhost = "github.localhost"
+
+	maxConcurrentSearches = 4
+
+	// Depth used in deep scans when the distance to the default branch is unknown.
+	defaultHistoryDepth = 30
+	// Upper bound of the depth derived from the distance to the default branch.
+	maxAutoHistoryDepth = 1000
 )
 
 var ErrNotFound = errors.New("not found")
 
+// Repositories to search for pull requests on a single GitHub host.
+type hostRepoNames struct {
+	hostname  string
+	repoNames []string
+}
+
+type Options struct {
+	// Number of git processes and API calls run in parallel. Defaults to the number of CPUs.
+	Jobs int
+	// Pool shared with other repositories cleaned up at the same time. A pool of Jobs workers is created if nil.
+	Pool WorkerPool
+	// Number of commits per branch inspected in deep scans.
+	// Defaults to the distance from the branch to its merge base with the default branch.
+	Depth int
+	// Whether to also clean up the branches of initialized submodules.
+	RecurseSubmodules bool
+	// Whether to delete branches even if stash entries were created on them.
+	IgnoreStashes bool
+	// Whether to move the commits added after a PR was merged to a new branch, so that the branch can be deleted.
+	Salvage bool
+	// Patterns of the branches besides the default branch that PRs count as merged into (e.g. `release/*`).
+	IntegrationBranches []string
+	// Patterns of the long-lived branches that are kept and treated like the default branch (e.g. `develop`).
+	// PRs merged into them count as merged as well.
+	TrunkBranches []string
+	// Labels that keep the branch of a PR after it is merged or closed (e.g. `keep-branch`).
+	KeepLabels []string
+}
+
 // Returns a list of remotes prioritized for PR discovery.
 // Both modes prioritize "origin," and when searching for pull requests,
 // the parent (a.k.a upstream) repository is also included in the search.
@@ -36,10 +73,13 @@ var ErrNotFound = errors.New("not found")
 //   - Scans all registered remotes to ensure comprehensive PR discovery.
 //   - Useful for complex setups where PRs may span multiple forks or parents.
 func GetPreferredRemotes(ctx context.Context, connection shared.Connection, scan shared.ScanMode) ([]shared.Remote, error) {
-	remotes, err := conn.GetRemoteNames(ctx, connection)
+	remotes, skipped, err := conn.GetRemoteNames(ctx, connection)
 	if err != nil {
 		return []shared.Remote{}, err
 	}
+	for _, remote := range skipped {
+		fmt.Fprintf(os.Stderr, "warning: skipped remote '%s' (%s): %s\n", remote.Name, remote.Url, remote.Reason)
+	}
 	if len(remotes) == 0 {
 		return []shared.Remote{}, ErrNotFound
 	}
@@ -130,30 +170,39 @@ func findHostname(params []string, defaultName string) string {
 	return defaultName
 }
 
-func GetBranches(ctx context.Context, remotes []shared.Remote, connection shared.Connection, state shared.PullRequestState, scan shared.ScanMode, dryRun bool) ([]shared.
+// Returns the default branch of the repository of the first remote.
+func GetDefaultBranchName(ctx context.Context, remotes []shared.Remote, connection shared.Connection) (string, error) {
+	repo, err := connection.GetRepoNames(ctx, remotes[0].Hostname, remotes[0].ResolvedRepoName())
+	if err != nil {
+		return "", err
+	}
+	_, defaultBranchName := getRepo(repo)
+	return defaultBranchName, nil
+}
+
+func GetBranches(ctx context.Context, remotes []shared.Remote, connection shared.Connection, state shared.PullRequestState, scan shared.ScanMode, dryRun bool, opts Options) ([]shared.
 	Branch, error) {
-	var repoNames []string
+	var hosts []hostRepoNames
 	var defaultBranchName string
+	// The default branches of the scanned repositories, since a fork and its parent may have different ones
+	defaultBranchNames := map[string]string{}
 	var err error
 	if scan == shared.Quick {
-		if repos, e := connection.GetRepoNames(ctx, remotes[0].Hostname, remotes[0].ResolvedRepoName()); e == nil {
-			repoNames, defaultBranchName, err = getRepo(repos)
+		if repo, e := connection.GetRepoNames(ctx, remotes[0].Hostname, remotes[0].ResolvedRepoName()); e == nil {
+			var repoNames []string
+			repoNames, defaultBranchName = getRepo(repo)
+			hosts = addRepoNames(hosts, remotes[0].Hostname, repoNames)
+			addDefaultBranchNames(defaultBranchNames, repo)
 		} else {
 			err = e
 		}
 	} else {
-		uniqueRepoNames := make(map[string]bool)
 		first := true
 		for _, remote := range remotes {
-			if repos, e := connection.GetRepoNames(ctx, remote.Hostname, remote.ResolvedRepoName()); e == nil {
-				names, defaultName, e := getRepo(repos)
-				if e != nil {
-					err = e
-					continue
-				}
-				for _, name := range names {
-					uniqueRepoNames[name] = true
-				}
+			if repo, e := connection.GetRepoNames(ctx, remote.Hostname, remote.ResolvedRepoName()); e == nil {
+				names, defaultName := getRepo(repo)
+				hosts = addRepoNames(hosts, remote.Hostname, names)
+				addDefaultBranchNames(defaultBranchNames, repo)
 				if first {
 					defaultBranchName = defaultName
 				}
@@ -163,21 +212,50 @@ func GetBranches(ctx context.Context, remotes []shared.Remote, connection shared
 			}
 			first = false
 		}
-		for repoName := range uniqueRepoNames {
-			repoNames = append(repoNames, repoName)
-		}
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	branches, err := loadBranches(ctx, remotes[0], defaultBranchName, repoNames, connection, scan)
+	pool := opts.Pool
+	if pool == nil {
+		pool = NewWorkerPool(opts.Jobs)
+	}
+	branches, err := loadBranches(ctx, remotes, defaultBranchName, hosts, connection, scan, opts, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	branches = applyBaseBranches(branches, defaultBranchName, defaultBranchNames, slices.Concat(opts.IntegrationBranches, opts.TrunkBranches))
+
+	branches = applyKeepLabels(branches, opts.KeepLabels)
+
+	branches, err = applyContentMatches(ctx, remotes, branches, defaultBranchName, connection, state)
 	if err != nil {
 		return nil, err
 	}
 
+	branches, err = applyReverts(ctx, remotes, branches, defaultBranchName, connection, state)
+	if err != nil {
+		return nil, err
+	}
+
+	branches = applyProtections(ctx, remotes, branches, defaultBranchName, connection, state)
+
 	branches = checkDeletion(branches, state)
 
+	branches, err = applyChildBranchesOfDeletable(ctx, branches, defaultBranchName, opts.TrunkBranches, connection, scan, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Salvage {
+		branches, err = salvageBranches(ctx, remotes, branches, defaultBranchName, connection, state, dryRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	branches, err = switchToDefaultBranchIfDeleted(ctx, remotes, branches, defaultBranchName, connection, dryRun)
 	if err != nil {
 		return nil, err
@@ -188,13 +266,14 @@ func GetBranches(ctx context.Context, remotes []shared.Remote, connection shared
 	return branches, nil
 }
 
-func loadBranches(ctx context.Context, remote shared.Remote, defaultBranchName string, repoNames []string, connection shared.Connection, scan shared.ScanMode) ([]shared.Branch, error) {
+func loadBranches(ctx context.Context, remotes []shared.Remote, defaultBranchName string, hosts []hostRepoNames, connection shared.Connection, scan shared.ScanMode, opts Options, pool WorkerPool) ([]shared.Branch, error) {
 	var branches []shared.Branch
 
 	if names, err := connection.GetBranchNames(ctx); err == nil {
 		branches = ToBranch(SplitLines(names))
 		branches = applyDefault(branches, defaultBranchName)
-		mergedNames, err := connection.GetMergedBranchNames(ctx, remote.Name, defaultBranchName)
+		branches = applyTrunks(branches, opts.TrunkBranches)
+		mergedNames, err := connection.GetMergedBranchNames(ctx, remotes[0].Name, defaultBranchName)
 		if err != nil {
 			return nil, err
 		}
@@ -203,7 +282,7 @@ func loadBranches(ctx context.Context, remote shared.Remote, defaultBranchName s
 		if err != nil {
 			return nil, err
 		}
-		branches, err = applyCommits(ctx, branches, defaultBranchName, connection, scan)
+		branches, err = applyCommits(ctx, branches, defaultBranchName, opts.TrunkBranches, connection, scan, opts.Depth, pool)
 		if err != nil {
 			return nil, err
 		}
@@ -215,13 +294,17 @@ func loadBranches(ctx context.Context, remote shared.Remote, defaultBranchName s
 		if err != nil {
 			return nil, err
 		}
+		if !opts.IgnoreStashes {
+			branches, err = applyStashes(ctx, branches, connection)
+			if err != nil {
+				return nil, err
+			}
+		}
 	} else {
 		return nil, err
 	}
 
 	prs := []shared.PullRequest{}
-	orgs := shared.GetQueryOrgs(repoNames)
-	repos := shared.GetQueryRepos(repoNames)
 
 	type pullRequestResult struct {
 		prs []shared.PullRequest
@@ -229,27 +312,32 @@ func loadBranches(ctx context.Context, remote shared.Remote, defaultBranchName s
 	}
 
 	queryHashes := shared.GetQueryHashes(branches)
-	prChan := make(chan pullRequestResult, len(queryHashes))
+	prChan := make(chan pullRequestResult, len(hosts)*len(queryHashes))
+	// Concurrent search requests are likely to trigger GitHub's secondary rate limits
+	searchSem := make(chan struct{}, maxConcurrentSearches)
 	var wg sync.WaitGroup
 
-	for _, queryHash := range queryHashes {
-		wg.Add(1)
-		go func(hash string) {
-			defer wg.Done()
-			pullRequests, err := connection.GetPullRequests(ctx, remote.Hostname, orgs, repos, hash)
-			if err != nil {
-				prChan <- pullRequestResult{err: err}
-				return
-			}
-
-			pr, err := toPullRequests(pullRequests)
-			if err != nil {
-				prChan <- pullRequestResult{err: err}
-				return
-			}
+	// Each host only knows its own repositories, so the search is sent to every host separately
+	for _, host := range hosts {
+		orgs := shared.GetQueryOrgs(host.repoNames)
+		repos := shared.GetQueryRepos(host.repoNames)
+		for _, queryHash := range queryHashes {
+			wg.Add(1)
+			searchSem <- struct{}{}
+			pool.acquire()
+			go func(hostname string, hash string) {
+				defer wg.Done()
+				defer func() { <-searchSem }()
+				defer pool.release()
+				pullRequests, err := connection.GetPullRequests(ctx, hostname, orgs, repos, hash)
+				if err != nil {
+					prChan <- pullRequestResult{err: err}
+					return
+				}
 
-			prChan <- pullRequestResult{prs: pr}
-		}(queryHash)
+				prChan <- pullRequestResult{prs: pullRequests}
+			}(host.hostname, queryHash)
+		}
 	}
 
 	go func() {
@@ -264,7 +352,8 @@ func loadBranches(ctx context.Context, remote shared.Remote, defaultBranchName s
 		prs = append(prs, result.prs...)
 	}
 
-	branches = applyPullRequest(ctx, branches, prs, connection)
+	branches = applyPullRequest(ctx, branches, prs, hosts, connection)
+	branches = applyStackedBranches(ctx, branches, prs, connection)
 
 	return branches, nil
 }
@@ -292,6 +381,17 @@ func applyDefault(branches []shared.Branch, defaultBranchName string) []shared.B
 	return results
 }
 
+func applyTrunks(branches []shared.Branch, trunkBranches []string) []shared.Branch {
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		if !branch.IsDefault && matchesBranchPattern(branch.Name, trunkBranches) {
+			branch.IsTrunk = true
+		}
+		results = append(results, branch)
+	}
+	return results
+}
+
 func applyMerged(branches []shared.Branch, mergedNames []string) []shared.Branch {
 	results := []shared.Branch{}
 	for _, branch := range branches {
@@ -324,7 +424,85 @@ func applyLocked(ctx context.Context, branches []shared.Branch, connection share
 	return results, nil
 }
 
-func applyCommits(ctx context.Context, branches []shared.Branch, defaultBranchName string, connection shared.Connection, scan shared.ScanMode) ([]shared.Branch, error) {
+func applyStashes(ctx context.Context, branches []shared.Branch, connection shared.Connection) ([]shared.Branch, error) {
+	stashedNames, err := conn.GetStashedBranchNames(ctx, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		branch.HasStashes = slices.Contains(stashedNames, branch.Name)
+		results = append(results, branch)
+	}
+	return results, nil
+}
+
+// Records the local branches that track another local branch as their upstream,
+// i.e. `branch.<child>.remote` is `.` and `branch.<child>.merge` is `refs/heads/<parent>`.
+func applyStackedBranches(ctx context.Context, branches []shared.Branch, prs []shared.PullRequest, connection shared.Connection) []shared.Branch {
+	childNames := map[string][]string{}
+	deletedBases := map[string]*shared.DeletedBase{}
+	for _, branch := range branches {
+		if branch.IsDetached() {
+			continue
+		}
+		mergeConfig, _ := connection.GetConfig(ctx, fmt.Sprintf("branch.%s.merge", branch.Name))
+		parentName, ok := strings.CutPrefix(strings.TrimSpace(mergeConfig), "refs/heads/")
+		if !ok || parentName == branch.Name {
+			continue
+		}
+		// The remote is only looked up when the upstream could be a local branch, or a deleted one that was merged
+		var deletedBase *shared.DeletedBase
+		if !BranchNameExists(parentName, branches) {
+			if deletedBase = findDeletedBase(branch, parentName, prs); deletedBase == nil {
+				continue
+			}
+		}
+		remoteConfig, _ := connection.GetConfig(ctx, fmt.Sprintf("branch.%s.remote", branch.Name))
+		if strings.TrimSpace(remoteConfig) != "." {
+			continue
+		}
+		if deletedBase != nil {
+			deletedBases[branch.Name] = deletedBase
+		} else {
+			childNames[parentName] = append(childNames[parentName], branch.Name)
+		}
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		for _, name := range childNames[branch.Name] {
+			if !slices.Contains(branch.StackedBranchNames, name) {
+				branch.StackedBranchNames = append(branch.StackedBranchNames, name)
+			}
+		}
+		if deletedBase, ok := deletedBases[branch.Name]; ok {
+			branch.DeletedBase = deletedBase
+		}
+		results = append(results, branch)
+	}
+	return results
+}
+
+// Finds the merged PR of the deleted base branch among the PRs of the branch commits,
+// and returns the newest of its commits the branch is built on.
+func findDeletedBase(branch shared.Branch, baseName string, prs []shared.PullRequest) *shared.DeletedBase {
+	for _, pr := range prs {
+		if pr.Name != baseName || pr.State != shared.Merged {
+			continue
+		}
+		// The commits of the branch are ordered from newest to oldest
+		for _, oid := range branch.Commits {
+			if slices.Contains(pr.Commits, oid) {
+				return &shared.DeletedBase{Name: baseName, Oid: oid}
+			}
+		}
+	}
+	return nil
+}
+
+func applyCommits(ctx context.Context, branches []shared.Branch, defaultBranchName string, trunkBranches []string, connection shared.Connection, scan shared.ScanMode, depth int, pool WorkerPool) ([]shared.Branch, error) {
 	var wg sync.WaitGroup
 
 	type remoteBranchResult struct {
@@ -334,34 +512,49 @@ func applyCommits(ctx context.Context, branches []shared.Branch, defaultBranchNa
 
 	results := []shared.Branch{}
 	resultChan := make(chan remoteBranchResult, len(branches))
+	loadCommitGraph := sync.OnceValues(func() (*shared.CommitGraph, error) {
+		return conn.GetCommitGraph(ctx, connection, defaultBranchName)
+	})
 
 	for _, branch := range branches {
 		wg.Add(1)
+		pool.acquire()
 		go func(branch shared.Branch) {
 			defer wg.Done()
+			defer pool.release()
 
-			if branch.Name == defaultBranchName || branch.IsDetached() {
+			if branch.Name == defaultBranchName || branch.IsTrunk || branch.IsDetached() {
 				branch.Commits = []string{}
 				resultChan <- remoteBranchResult{branch: branch}
 				return
 			}
 
-			oids, err := connection.GetLog(ctx, branch.Name)
+			// Only the latest commit is needed unless the history of an unmerged branch is traced
+			maxCount := 1
+			var graph *shared.CommitGraph
+			if scan == shared.Deep && !branch.IsMerged {
+				var err error
+				graph, err = loadCommitGraph()
+				if err != nil {
+					resultChan <- remoteBranchResult{err: err}
+					return
+				}
+				maxCount = historyDepth(branch, depth, graph)
+			}
+
+			oids, err := connection.GetLog(ctx, branch.Name, maxCount)
 			if err != nil {
 				resultChan <- remoteBranchResult{err: err}
 				return
 			}
 
 			if logOids := SplitLines(oids); len(logOids) > 0 {
-				if scan == shared.Quick {
+				if graph == nil {
 					branch.Commits = []string{logOids[0]}
 				} else {
-					trimmedOids, err := trimBranch(ctx, logOids, branch, defaultBranchName, connection)
-					if err != nil {
-						resultChan <- remoteBranchResult{err: err}
-						return
-					}
-					branch.Commits = trimmedOids
+					branch.Commits = trimBranch(logOids, branch, defaultBranchName, trunkBranches, graph)
+					branch.StackedBranchNames = getChildBranchNames(logOids[0], branch, defaultBranchName, trunkBranches, graph)
+					branch.IsTruncated = len(logOids) >= maxCount && len(branch.Commits) == len(logOids)
 				}
 			} else {
 				branch.Commits = []string{}
@@ -390,14 +583,18 @@ func applyTrackedChanges(ctx context.Context, branches []shared.Branch, connecti
 
 	for _, branch := range branches {
 		changes := []shared.UncommittedChange{}
+		opts := []string{}
 		var err error
-		if branch.Head {
+		if branch.Worktree != nil && branch.Worktree.IsBare {
+			// A bare repository has no working tree
+		} else if branch.Head {
 			changes, err = conn.GetUncommittedChanges(ctx, connection)
 			if err != nil {
 				return results, err
 			}
 		} else if branch.Worktree != nil && !branch.Worktree.IsPrunable {
-			changes, err = conn.GetUncommittedChanges(ctx, connection, "-C", branch.Worktree.Path)
+			opts = []string{"-C", branch.Worktree.Path}
+			changes, err = conn.GetUncommittedChanges(ctx, connection, opts...)
 			if err != nil {
 				return results, err
 			}
@@ -410,6 +607,18 @@ func applyTrackedChanges(ctx context.Context, branches []shared.Branch, connecti
 				branch.HasTrackedChanges = true
 			}
 		}
+
+		// Changes inside a submodule are reported as a modification of the submodule path.
+		// The submodules only refine the reason the branch is kept, so they are ignored if they cannot be read
+		// (e.g. a nested repository added without a .gitmodules entry).
+		if branch.HasTrackedChanges {
+			submodules, _ := conn.GetSubmodules(ctx, connection, opts...)
+			for _, change := range changes {
+				if slices.ContainsFunc(submodules, func(s shared.Submodule) bool { return s.Path == change.Path }) {
+					branch.HasModifiedSubmodules = true
+				}
+			}
+		}
 		results = append(results, branch)
 	}
 
@@ -435,14 +644,65 @@ func applyWorktrees(ctx context.Context, branches []shared.Branch, connection sh
 	for _, branch := range branches {
 		if wt, ok := worktreeMap[branch.Name]; ok {
 			branch.Worktree = wt
+		} else if branch.Head && len(worktrees) > 0 && worktrees[0].IsBare {
+			// Running in a bare repository, where HEAD is not checked out in any worktree
+			branch.Worktree = &worktrees[0]
+		}
+		results = append(results, branch)
+	}
+
+	return applyOperations(ctx, results, worktrees, connection)
+}
+
+func applyOperations(ctx context.Context, branches []shared.Branch, worktrees []shared.Worktree, connection shared.Connection) ([]shared.Branch, error) {
+	operationMap := make(map[string]string)
+	for _, worktree := range worktrees {
+		if worktree.IsBare || worktree.IsPrunable {
+			continue
+		}
+		operations, err := conn.GetOperations(ctx, connection, worktree.Path)
+		if err != nil {
+			return nil, err
+		}
+		for _, operation := range operations {
+			// A rebase detaches HEAD, so the branch is taken from the state files when they record it
+			name := operation.BranchName
+			if name == "" {
+				name = worktree.Branch
+			}
+			if _, ok := operationMap[name]; name != "" && !ok {
+				operationMap[name] = operation.Kind
+			}
 		}
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		branch.Operation = operationMap[branch.Name]
 		results = append(results, branch)
 	}
 
 	return results, nil
 }
 
-func trimBranch(ctx context.Context, oids []string, branch shared.Branch, defaultBranchName string, connection shared.Connection) ([]string, error) {
+// Returns the number of commits to inspect for the branch.
+// Without an explicit depth, the commits up to the merge base with the default branch are inspected,
+// plus the merge base itself so that the fork point can be detected.
+func historyDepth(branch shared.Branch, depth int, graph *shared.CommitGraph) int {
+	if depth > 0 {
+		return depth
+	}
+	count, ok := graph.CountExclusiveCommits("refs/heads/" + branch.Name)
+	if !ok {
+		return defaultHistoryDepth
+	}
+	return min(count+1, maxAutoHistoryDepth)
+}
+
+// Returns the commits the branch adds on top of the other branches, newest first.
+// The history stops at the first commit another branch contains, except for the branches built on top of this one.
+// A trunk branch is never treated as built on top, so a branch merged into it keeps only its latest commit.
+func trimBranch(oids []string, branch shared.Branch, defaultBranchName string, trunkBranches []string, graph *shared.CommitGraph) []string {
 	results := []string{}
 	childNames := []string{}
 
@@ -452,16 +712,18 @@ func trimBranch(ctx context.Context, oids []string, branch shared.Branch, defaul
 			break
 		}
 
-		refNames, err := connection.GetAssociatedRefNames(ctx, oid)
-		if err != nil {
-			return nil, err
+		names := []string{}
+		for _, ref := range graph.ContainingRefs(oid) {
+			names = append(names, ref.BranchName())
 		}
-		names := extractBranchNames(SplitLines(refNames))
 
 		if i == 0 {
 			for _, name := range names {
 				if name == defaultBranchName {
-					return []string{}, nil
+					return []string{}
+				}
+				if name != branch.Name && matchesBranchPattern(name, trunkBranches) {
+					return []string{oid}
 				}
 				if name != branch.Name {
 					childNames = append(childNames, name)
@@ -471,26 +733,65 @@ func trimBranch(ctx context.Context, oids []string, branch shared.Branch, defaul
 
 		for _, name := range names {
 			if name != branch.Name && !slices.Contains(childNames, name) {
-				return results, nil
+				return results
 			}
 		}
 
 		results = append(results, oid)
 	}
 
-	return results, nil
+	return results
 }
 
-func extractBranchNames(refNames []string) []string {
-	result := []string{}
-	r := regexp.MustCompile(`^refs/(?:heads|remotes/.+?)/`)
-	for _, name := range refNames {
-		result = append(result, r.ReplaceAllString(name, ""))
+// Returns the local branches that are built on top of the branch, i.e. contain its latest commit.
+func getChildBranchNames(headOid string, branch shared.Branch, defaultBranchName string, trunkBranches []string, graph *shared.CommitGraph) []string {
+	results := []string{}
+	for _, ref := range graph.ContainingRefs(headOid) {
+		// A branch on the same commit (e.g. a backup of the branch) is not built on top of it
+		if !strings.HasPrefix(ref.Name, "refs/heads/") || ref.Oid == headOid {
+			continue
+		}
+		name := ref.BranchName()
+		if name != branch.Name && name != defaultBranchName && !matchesBranchPattern(name, trunkBranches) && !slices.Contains(results, name) {
+			results = append(results, name)
+		}
 	}
-	return result
+	return results
+}
+
+// Quick scans don't load the commit graph, so the branches built directly on top of a branch are only looked up
+// for the branches that would be deleted, and the deletion is checked again with them.
+// Branches merged into the default branch are not in the graph, and deleting them leaves the branches on them intact.
+func applyChildBranchesOfDeletable(ctx context.Context, branches []shared.Branch, defaultBranchName string, trunkBranches []string, connection shared.Connection, scan shared.ScanMode, state shared.PullRequestState) ([]shared.Branch, error) {
+	if scan != shared.Quick || !slices.ContainsFunc(branches, func(b shared.Branch) bool { return b.State == shared.Deletable }) {
+		return branches, nil
+	}
+
+	graph, err := conn.GetCommitGraph(ctx, connection, defaultBranchName)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		if branch.State == shared.Deletable && len(branch.Commits) > 0 {
+			for _, name := range getChildBranchNames(branch.Commits[0], branch, defaultBranchName, trunkBranches, graph) {
+				if !slices.Contains(branch.StackedBranchNames, name) {
+					branch.StackedBranchNames = append(branch.StackedBranchNames, name)
+				}
+			}
+		}
+		results = append(results, branch)
+	}
+	return checkDeletion(results, state), nil
 }
 
-func applyPullRequest(ctx context.Context, branches []shared.Branch, prs []shared.PullRequest, connection shared.Connection) []shared.Branch {
+func applyPullRequest(ctx context.Context, branches []shared.Branch, prs []shared.PullRequest, hosts []hostRepoNames, connection shared.Connection) []shared.Branch {
+	scannedRepoNames := []string{}
+	for _, host := range hosts {
+		scannedRepoNames = append(scannedRepoNames, host.repoNames...)
+	}
+
 	prNumbers := map[string]int{}
 	for _, branch := range branches {
 		if branch.IsDetached() {
@@ -502,9 +803,24 @@ func applyPullRequest(ctx context.Context, branches []shared.Branch, prs []share
 		}
 	}
 
+	// A branch may be pushed to any remote, not only to the ones scanned for PRs (e.g. a fork in quick scans)
+	loadRemotes := sync.OnceValue(func() []shared.Remote {
+		remotes, _, _ := conn.GetRemoteNames(ctx, connection)
+		return remotes
+	})
+
 	results := []shared.Branch{}
 	for _, branch := range branches {
-		prs := findMatchedPullRequest(branch.Name, prs, prNumbers)
+		headRepoNames := scannedRepoNames
+		// The upstream is only looked up when a PR with the same name comes from a repository that was not scanned
+		if slices.ContainsFunc(prs, func(pr shared.PullRequest) bool {
+			return pr.Name == branch.Name && !isKnownHeadRepo(pr, scannedRepoNames)
+		}) {
+			if remote, ok := getUpstreamRemote(ctx, branch, loadRemotes(), connection); ok {
+				headRepoNames = append(slices.Clone(scannedRepoNames), remote.RepoName)
+			}
+		}
+		prs := findMatchedPullRequest(branch, prs, prNumbers, headRepoNames)
 		sort.Slice(prs, func(i, j int) bool { return prs[i].Number < prs[j].Number })
 		branch.PullRequests = prs
 		results = append(results, branch)
@@ -512,6 +828,22 @@ func applyPullRequest(ctx context.Context, branches []shared.Branch, prs []share
 	return results
 }
 
+func getUpstreamRemote(ctx context.Context, branch shared.Branch, remotes []shared.Remote, connection shared.Connection) (shared.Remote, bool) {
+	if branch.IsDetached() {
+		return shared.Remote{}, false
+	}
+	remoteConfig, _ := connection.GetConfig(ctx, fmt.Sprintf("branch.%s.remote", branch.Name))
+	splitConfig := SplitLines(remoteConfig)
+	if len(splitConfig) == 0 {
+		return shared.Remote{}, false
+	}
+	i := slices.IndexFunc(remotes, func(remote shared.Remote) bool { return remote.Name == splitConfig[0] })
+	if i < 0 {
+		return shared.Remote{}, false
+	}
+	return remotes[i], true
+}
+
 func getPRNumber(mergeConfig string) int {
 	r := regexp.MustCompile(`^refs/pull/(\d+)`)
 	found := r.FindStringSubmatch(mergeConfig)
@@ -526,7 +858,11 @@ func getPRNumber(mergeConfig string) int {
 	}
 }
 
-func findMatchedPullRequest(branchName string, prs []shared.PullRequest, prNumbers map[string]int) []shared.PullRequest {
+// PRs linked by number via `gh pr checkout` are trusted as is,
+// while PRs matched by branch name must come from a known repository,
+// since any fork in the searched organizations may have a branch with the same name.
+// The head repository of a PR is unknown once it is deleted, so such PRs must contain a commit of the branch instead.
+func findMatchedPullRequest(branch shared.Branch, prs []shared.PullRequest, prNumbers map[string]int, headRepoNames []string) []shared.PullRequest {
 	results := []shared.PullRequest{}
 
 	prExists := func(pr shared.PullRequest) bool {
@@ -553,10 +889,10 @@ func findMatchedPullRequest(branchName string, prs []shared.PullRequest, prNumbe
 		}
 
 		if prNumberExists(pr.Number) {
-			if pr.Number == prNumbers[branchName] {
+			if pr.Number == prNumbers[branch.Name] {
 				results = append(results, pr)
 			}
-		} else if pr.Name == branchName {
+		} else if pr.Name == branch.Name && (isKnownHeadRepo(pr, headRepoNames) || (pr.HeadRepoName == "" && containsBranchCommit(pr, branch))) {
 			results = append(results, pr)
 		}
 	}
@@ -564,27 +900,56 @@ func findMatchedPullRequest(branchName string, prs []shared.PullRequest, prNumbe
 	return results
 }
 
+func containsBranchCommit(pr shared.PullRequest, branch shared.Branch) bool {
+	return slices.ContainsFunc(branch.Commits, func(oid string) bool { return slices.Contains(pr.Commits, oid) })
+}
+
+func isKnownHeadRepo(pr shared.PullRequest, headRepoNames []string) bool {
+	return slices.ContainsFunc(headRepoNames, func(name string) bool {
+		return strings.EqualFold(name, pr.HeadRepoName)
+	})
+}
+
 func checkDeletion(branches []shared.Branch, state shared.PullRequestState) []shared.Branch {
 	results := []shared.Branch{}
 	for _, branch := range branches {
 		branch.State = getDeleteStatus(branch, state)
 		results = append(results, branch)
 	}
+
+	// A branch other branches are stacked on is kept unless all of them are deleted as well.
+	// Protecting a branch can in turn protect the branch below it, so this repeats until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for i, branch := range results {
+			if branch.State != shared.Deletable {
+				continue
+			}
+			for _, name := range branch.StackedBranchNames {
+				if j := slices.IndexFunc(results, func(b shared.Branch) bool { return b.Name == name }); j >= 0 && results[j].State != shared.Deletable {
+					results[i].State = shared.NotDeletable
+					changed = true
+					break
+				}
+			}
+		}
+	}
 	return results
 }
 
 func getDeleteStatus(branch shared.Branch, state shared.PullRequestState) shared.BranchState {
-	if branch.IsLocked {
+	if branch.IsLocked || branch.IsProtectedOnGitHub || branch.IsTrunk {
 		return shared.NotDeletable
 	}
 
 	if branch.Worktree != nil {
-		if branch.Worktree.IsLocked || (branch.Worktree.IsMain && !branch.Head) || (!branch.Worktree.IsMain && branch.Head) || (!branch.Worktree.IsMain && branch.HasUntrackedFiles) {
+		// Deleting the branch HEAD of a bare repository points to would leave HEAD dangling
+		if branch.Worktree.IsBare || branch.Worktree.IsLocked || (branch.Worktree.IsMain && !branch.Head) || (!branch.Worktree.IsMain && branch.Head) || (!branch.Worktree.IsMain && branch.HasUntrackedFiles) {
 			return shared.NotDeletable
 		}
 	}
 
-	if branch.HasTrackedChanges {
+	if branch.HasTrackedChanges || branch.HasStashes || branch.Operation != "" {
 		return shared.NotDeletable
 	}
 
@@ -594,7 +959,7 @@ func getDeleteStatus(branch shared.Branch, state shared.PullRequestState) shared
 
 	fullyMergedCnt := 0
 	for _, pr := range branch.PullRequests {
-		if pr.State == shared.Open {
+		if pr.State == shared.Open || pr.IsReverted || pr.KeepLabel != "" {
 			return shared.NotDeletable
 		}
 		if isFullyMerged(branch, pr, state) {
@@ -608,25 +973,356 @@ func getDeleteStatus(branch shared.Branch, state shared.PullRequestState) shared
 	return shared.Deletable
 }
 
+// Returns the branches stacked directly on the branch, excluding the ones stacked on them in turn.
+func GetDirectChildNames(branch shared.Branch, branches []shared.Branch) []string {
+	results := []string{}
+	for _, name := range branch.StackedBranchNames {
+		isGrandchild := slices.ContainsFunc(branches, func(other shared.Branch) bool {
+			return other.Name != name && slices.Contains(branch.StackedBranchNames, other.Name) &&
+				slices.Contains(other.StackedBranchNames, name)
+		})
+		if !isGrandchild {
+			results = append(results, name)
+		}
+	}
+	return results
+}
+
+// Reports whether other branches are stacked on the branch and it would be deleted without them.
+func IsMergedBase(branch shared.Branch, state shared.PullRequestState) bool {
+	return len(branch.StackedBranchNames) > 0 && getDeleteStatus(branch, state) == shared.Deletable
+}
+
 func isFullyMerged(branch shared.Branch, pr shared.PullRequest, state shared.PullRequestState) bool {
 	if len(branch.Commits) == 0 {
 		return false
 	}
-	if (state == shared.Merged && pr.State != shared.Merged) ||
-		// In the GitHub interface, closed status includes merged status, so we make it behave the same way.
-		// https://github.com/cli/cli/issues/8102
-		(state == shared.Closed && pr.State != shared.Closed && pr.State != shared.Merged) {
+	if !matchesState(pr, state) {
 		return false
 	}
 
 	localHeadOid := branch.Commits[0]
-	if slices.Contains(pr.Commits, localHeadOid) {
+	if slices.Contains(pr.Commits, localHeadOid) || pr.MatchedBy != "" {
 		return true
 	}
 
 	return false
 }
 
+func matchesState(pr shared.PullRequest, state shared.PullRequestState) bool {
+	return (state == shared.Merged && pr.State == shared.Merged && !pr.IsMergedElsewhere) ||
+		// In the GitHub interface, closed status includes merged status, so we make it behave the same way.
+		// https://github.com/cli/cli/issues/8102
+		(state == shared.Closed && (pr.State == shared.Closed || pr.State == shared.Merged))
+}
+
+// Marks the merged PRs whose base branch is neither the default branch of their base repository nor an integration branch,
+// such as a PR merged into a feature branch that was abandoned later.
+// PRs whose base branch is unknown are assumed to be merged into the default branch,
+// and PRs of a repository that was not scanned are compared with the default branch of the first remote.
+func applyBaseBranches(branches []shared.Branch, defaultBranchName string, defaultBranchNames map[string]string, integrationBranches []string) []shared.Branch {
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		prs := []shared.PullRequest{}
+		for _, pr := range branch.PullRequests {
+			baseDefaultBranchName := defaultBranchName
+			if name := defaultBranchNames[pr.BaseRepoName]; name != "" {
+				baseDefaultBranchName = name
+			}
+			if pr.State == shared.Merged && pr.BaseRefName != "" && pr.BaseRefName != baseDefaultBranchName &&
+				!matchesBranchPattern(pr.BaseRefName, integrationBranches) {
+				pr.IsMergedElsewhere = true
+			}
+			prs = append(prs, pr)
+		}
+		branch.PullRequests = prs
+		results = append(results, branch)
+	}
+	return results
+}
+
+// Marks the PRs labeled with any of the keep labels, such as a PR whose branch a backport bot still needs.
+// Labels are compared case-insensitively, as GitHub does.
+func applyKeepLabels(branches []shared.Branch, keepLabels []string) []shared.Branch {
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		prs := []shared.PullRequest{}
+		for _, pr := range branch.PullRequests {
+			for _, label := range pr.Labels {
+				if slices.ContainsFunc(keepLabels, func(keep string) bool { return strings.EqualFold(keep, label) }) {
+					pr.KeepLabel = label
+					break
+				}
+			}
+			prs = append(prs, pr)
+		}
+		branch.PullRequests = prs
+		results = append(results, branch)
+	}
+	return results
+}
+
+// Reports whether the branch name matches any of the patterns, where `*` matches any characters except `/`.
+func matchesBranchPattern(name string, patterns []string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	})
+}
+
+// Marks the PRs whose content the branch contains although its latest commit is not one of the PR commits,
+// as happens when the branch is rebased or amended locally after pushing.
+// The tree of the latest commit is compared first, then the patch-ids of the commits the branch adds to the default branch.
+func applyContentMatches(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, defaultBranchName string, connection shared.Connection, state shared.PullRequestState) ([]shared.Branch, error) {
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		if branch.IsDefault || branch.IsDetached() || len(branch.Commits) == 0 {
+			results = append(results, branch)
+			continue
+		}
+
+		var localTree string
+		var localPatchIds []string
+		prs := []shared.PullRequest{}
+		for _, pr := range branch.PullRequests {
+			if !matchesState(pr, state) || slices.Contains(pr.Commits, branch.Commits[0]) {
+				prs = append(prs, pr)
+				continue
+			}
+
+			if pr.HeadTreeOid != "" {
+				if localTree == "" {
+					tree, err := connection.GetTree(ctx, branch.Name)
+					if err != nil {
+						return nil, err
+					}
+					localTree = strings.TrimSpace(tree)
+				}
+				if localTree == pr.HeadTreeOid {
+					pr.MatchedBy = "tree"
+					prs = append(prs, pr)
+					continue
+				}
+			}
+
+			if localPatchIds == nil {
+				patchIds, err := conn.GetPatchIds(ctx, connection, branch.Name, remotes[0].Name+"/"+defaultBranchName)
+				if err != nil {
+					return nil, err
+				}
+				localPatchIds = patchIds
+			}
+			prPatchIds, err := conn.GetCommitPatchIds(ctx, connection, pr.Commits)
+			if err != nil {
+				return nil, err
+			}
+			if len(localPatchIds) > 0 && !slices.ContainsFunc(localPatchIds, func(id string) bool { return !slices.Contains(prPatchIds, id) }) {
+				pr.MatchedBy = "patch-id"
+			}
+			prs = append(prs, pr)
+		}
+		branch.PullRequests = prs
+		results = append(results, branch)
+	}
+	return results, nil
+}
+
+// Marks the merged PRs that were reverted on the default branch afterwards, since their branches are needed to land the changes again.
+// A revert is found by the PR number in the reverted subject or by the merge commit it names, and reverting the revert brings the PR back.
+func applyReverts(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, defaultBranchName string, connection shared.Connection, state shared.PullRequestState) ([]shared.Branch, error) {
+	hasMerged := false
+	mergeCommits := map[string]int{}
+	for _, branch := range branches {
+		for _, pr := range branch.PullRequests {
+			if pr.State != shared.Merged || !isFullyMerged(branch, pr, state) {
+				continue
+			}
+			hasMerged = true
+			if pr.MergeCommitOid != "" {
+				mergeCommits[pr.MergeCommitOid] = pr.Number
+			}
+		}
+	}
+	if !hasMerged {
+		return branches, nil
+	}
+
+	reverts, err := conn.GetReverts(ctx, connection, remotes[0].Name+"/"+defaultBranchName)
+	if err != nil {
+		return nil, err
+	}
+
+	isReverted := map[int]bool{}
+	// The PRs each revert changed, so that a revert naming another revert undoes its changes
+	changes := map[string]map[int]bool{}
+	for _, revert := range reverts {
+		changed := map[int]bool{}
+		for _, number := range revert.RevertedNumbers {
+			changed[number] = !revert.IsReapply
+		}
+		for _, oid := range revert.RevertedOids {
+			if previous, ok := changes[oid]; ok {
+				for number, reverted := range previous {
+					changed[number] = !reverted
+				}
+			} else if number, ok := mergeCommits[oid]; ok {
+				changed[number] = true
+			}
+		}
+		changes[revert.Oid] = changed
+		maps.Copy(isReverted, changed)
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		prs := []shared.PullRequest{}
+		for _, pr := range branch.PullRequests {
+			if pr.State == shared.Merged && isReverted[pr.Number] {
+				pr.IsReverted = true
+			}
+			prs = append(prs, pr)
+		}
+		branch.PullRequests = prs
+		results = append(results, branch)
+	}
+	return results, nil
+}
+
+// Marks the branches protected on GitHub by branch protection rules or rulesets, which are kept like locked branches.
+// The rules are only fetched when a branch would be deleted, and are skipped with a warning if they cannot be read
+// (e.g. without admin access to the repository).
+func applyProtections(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, defaultBranchName string, connection shared.Connection, state shared.PullRequestState) []shared.Branch {
+	if !slices.ContainsFunc(branches, func(b shared.Branch) bool { return getDeleteStatus(b, state) == shared.Deletable }) {
+		return branches
+	}
+
+	protections, err := connection.GetBranchProtections(ctx, remotes[0].Hostname, remotes[0].ResolvedRepoName())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: branches are not checked against the protection rules of %s: %v\n", remotes[0].ResolvedRepoName(), err)
+		return branches
+	}
+
+	results := []shared.Branch{}
+	for _, branch := range branches {
+		branch.IsProtectedOnGitHub = slices.ContainsFunc(protections, func(p shared.BranchProtection) bool {
+			return p.Matches(branch.Name, defaultBranchName)
+		})
+		results = append(results, branch)
+	}
+	return results
+}
+
+// Moves the commits added to a branch after its PR was merged to a new `<name>-followup` branch rebased onto the default branch,
+// so that the branch itself can be deleted. Branches whose commits cannot be rebased without conflicts are kept as they are.
+func salvageBranches(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, defaultBranchName string, connection shared.Connection, state shared.PullRequestState, dryRun bool) ([]shared.Branch, error) {
+	headIndex := slices.IndexFunc(branches, func(b shared.Branch) bool { return b.Head })
+	if headIndex < 0 {
+		return branches, nil
+	}
+	head := branches[headIndex]
+
+	// The local default branch may not have the merged changes yet
+	onto := remotes[0].Name + "/" + defaultBranchName
+
+	results := []shared.Branch{}
+	followups := []shared.Branch{}
+	for _, branch := range branches {
+		if branch.State != shared.NotDeletable || branch.IsDefault || branch.IsTrunk || branch.IsDetached() || len(branch.StackedBranchNames) > 0 ||
+			(branch.Worktree != nil && !branch.Worktree.IsMain) {
+			results = append(results, branch)
+			continue
+		}
+		mergedOid, err := findMergedOid(ctx, branch, state, connection)
+		if err != nil {
+			return nil, err
+		}
+		// The branch is salvageable only if it would be deleted without the commits added after the merge
+		merged := branch
+		merged.Commits = []string{mergedOid}
+		if mergedOid == "" || getDeleteStatus(merged, state) != shared.Deletable {
+			results = append(results, branch)
+			continue
+		}
+
+		followupName := branch.Name + "-followup"
+		if BranchNameExists(followupName, branches) {
+			fmt.Fprintf(os.Stderr, "warning: could not salvage '%s': '%s' already exists\n", branch.Name, followupName)
+			results = append(results, branch)
+			continue
+		}
+		if head.IsDetached() || head.HasTrackedChanges {
+			fmt.Fprintf(os.Stderr, "warning: could not salvage '%s': the current branch must be checked out without uncommitted changes\n", branch.Name)
+			results = append(results, branch)
+			continue
+		}
+
+		if !dryRun {
+			if _, err := connection.CreateBranch(ctx, followupName, branch.Name); err != nil {
+				return nil, err
+			}
+			// Rebasing checks out the new branch, which is kept checked out only when it replaces the current branch
+			if _, err := connection.RebaseBranch(ctx, followupName, mergedOid, onto); err != nil {
+				connection.AbortRebase(ctx)
+				connection.CheckoutBranch(ctx, head.Name, false)
+				connection.DeleteBranches(ctx, []string{followupName})
+				fmt.Fprintf(os.Stderr, "warning: could not salvage '%s' because of conflicts\n", branch.Name)
+				results = append(results, branch)
+				continue
+			}
+			if !branch.Head {
+				if _, err := connection.CheckoutBranch(ctx, head.Name, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		followups = append(followups, shared.Branch{
+			Head:    branch.Head,
+			Name:    followupName,
+			Commits: []string{},
+			State:   shared.NotDeletable,
+		})
+		branch.Head = false
+		branch.FollowupBranchName = followupName
+		branch.State = shared.Deletable
+		results = append(results, branch)
+	}
+
+	return append(results, followups...), nil
+}
+
+// Returns the latest commit of the branch that is included in a merged PR of the branch.
+func findMergedOid(ctx context.Context, branch shared.Branch, state shared.PullRequestState, connection shared.Connection) (string, error) {
+	prCommits := []string{}
+	for _, pr := range branch.PullRequests {
+		if pr.State == shared.Open {
+			return "", nil
+		}
+		if (state == shared.Merged && pr.State == shared.Merged) || state == shared.Closed {
+			prCommits = append(prCommits, pr.Commits...)
+		}
+	}
+	if len(prCommits) == 0 {
+		return "", nil
+	}
+
+	oids := branch.Commits
+	// Only the latest commit is loaded in quick scans
+	if !slices.ContainsFunc(oids, func(oid string) bool { return slices.Contains(prCommits, oid) }) {
+		log, err := connection.GetLog(ctx, branch.Name, defaultHistoryDepth)
+		if err != nil {
+			return "", err
+		}
+		oids = SplitLines(log)
+	}
+	for _, oid := range oids {
+		if slices.Contains(prCommits, oid) {
+			return oid, nil
+		}
+	}
+	return "", nil
+}
+
 func switchToDefaultBranchIfDeleted(ctx context.Context, remotes []shared.Remote, branches []shared.Branch, defaultBranchName string, connection shared.Connection, dryRun bool) ([]shared.Branch, error) {
 	needsCheckout := false
 	for _, branch := range branches {
@@ -640,6 +1336,14 @@ func switchToDefaultBranchIfDeleted(ctx context.Context, remotes []shared.Remote
 		return branches, nil
 	}
 
+	operations, err := conn.GetOperations(ctx, connection, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(operations) > 0 {
+		return nil, fmt.Errorf("cannot switch to the default branch because a %s is in progress; finish or abort it, then run again", operations[0].Kind)
+	}
+
 	results := []shared.Branch{}
 
 	var remoteName = remotes[0].Name
@@ -707,110 +1411,37 @@ func ToBranch(branchNames []string) []shared.Branch {
 	return results
 }
 
-func getRepo(jsonResp string) ([]string, string, error) {
-	type response struct {
-		DefaultBranchRef struct {
-			Name string
-		}
-		Name  string
-		Owner struct {
-			Login string
-		}
-		Parent struct {
-			Name  string
-			Owner struct {
-				Login string
-			}
-			DefaultBranchName string
+// Appends the repository names to the host they belong to, keeping the order in which hosts appear.
+func addRepoNames(hosts []hostRepoNames, hostname string, repoNames []string) []hostRepoNames {
+	i := slices.IndexFunc(hosts, func(host hostRepoNames) bool { return host.hostname == hostname })
+	if i < 0 {
+		hosts = append(hosts, hostRepoNames{hostname: hostname})
+		i = len(hosts) - 1
+	}
+	for _, name := range repoNames {
+		if !slices.Contains(hosts[i].repoNames, name) {
+			hosts[i].repoNames = append(hosts[i].repoNames, name)
 		}
 	}
+	return hosts
+}
 
-	var resp response
-	if err := json.Unmarshal([]byte(jsonResp), &resp); err != nil {
-		return nil, "", fmt.Errorf("error unmarshaling response: %w", err)
+func addDefaultBranchNames(defaultBranchNames map[string]string, repo shared.Repository) {
+	defaultBranchNames[repo.NameWithOwner()] = repo.DefaultBranchName
+	if repo.Parent != nil {
+		defaultBranchNames[repo.Parent.NameWithOwner()] = repo.Parent.DefaultBranchName
 	}
+}
 
+func getRepo(repo shared.Repository) ([]string, string) {
 	repoNames := []string{
-		resp.Owner.Login + "/" + resp.Name,
-	}
-	if len(resp.Parent.Name) > 0 {
-		repoNames = append(repoNames, resp.Parent.Owner.Login+"/"+resp.Parent.Name)
-	}
-
-	return repoNames, resp.DefaultBranchRef.Name, nil
-}
-
-func toPullRequests(jsonResp string) ([]shared.PullRequest, error) {
-	type response struct {
-		Data struct {
-			Search struct {
-				IssueCount int
-				Edges      []struct {
-					Node struct {
-						Number      int
-						HeadRefName string
-						HeadRefOid  string
-						Url         string
-						State       string
-						IsDraft     bool
-						Commits     struct {
-							Nodes []struct {
-								Commit struct {
-									Oid string
-								}
-							}
-						}
-						Author struct {
-							Login string
-						}
-					}
-				}
-			}
-		}
+		repo.NameWithOwner(),
 	}
-
-	var resp response
-	if err := json.Unmarshal([]byte(jsonResp), &resp); err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %w", err)
+	if repo.Parent != nil {
+		repoNames = append(repoNames, repo.Parent.NameWithOwner())
 	}
 
-	results := []shared.PullRequest{}
-	for _, edge := range resp.Data.Search.Edges {
-		state, err := toPullRequestState(edge.Node.State)
-		if err == ErrNotFound {
-			return nil, fmt.Errorf("unexpected pull request state: %s", edge.Node.State)
-		}
-
-		commits := []string{}
-		for _, node := range edge.Node.Commits.Nodes {
-			commits = append(commits, node.Commit.Oid)
-		}
-
-		results = append(results, shared.PullRequest{
-			Name:    edge.Node.HeadRefName,
-			State:   state,
-			IsDraft: edge.Node.IsDraft,
-			Number:  edge.Node.Number,
-			Commits: commits,
-			Url:     edge.Node.Url,
-			Author:  edge.Node.Author.Login,
-		})
-	}
-
-	return results, nil
-}
-
-func toPullRequestState(state string) (shared.PullRequestState, error) {
-	switch state {
-	case "CLOSED":
-		return shared.Closed, nil
-	case "MERGED":
-		return shared.Merged, nil
-	case "OPEN":
-		return shared.Open, nil
-	default:
-		return 0, ErrNotFound
-	}
+	return repoNames, repo.DefaultBranchName
 }
 
 func DeleteBranches(ctx context.Context, branches []shared.Branch, connection shared.Connection) ([]shared.Branch, error) {
@@ -121,6 +121,22 @@ func Test_GetPreferredRemotes(t *testing.T) {
 			assert.Equal(t, "upstream", actual[1].Name)
 			assert.Equal(t, "base", actual[1].GhResolved)
 		})
+
+		t.Run("skips remotes not hosted on a git server", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetRemoteNames("origin_local", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+				}, nil, nil)
+
+			actual, err := GetPreferredRemotes(context.Background(), s.Conn, scan)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(actual))
+			assert.Equal(t, "origin", actual[0].Name)
+		})
 	})
 }
 
@@ -142,6 +158,9 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main_issue1", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main_issue1Merged"}, {BranchName: "issue1", Filename: "issue1Merged"},
@@ -151,6 +170,9 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -169,7 +191,7 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -182,15 +204,12 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			s := conn.Setup(ctrl).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "b8a2645298053fb62ea03e27feea6c483d3fd27e", Filename: "main_issue1"},
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "main_issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil)
+				GetRefs("main_issue1Merged", nil, nil).
+				GetCommitGraph("empty", nil, nil)
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -209,7 +228,7 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -231,7 +250,7 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -241,6 +260,218 @@ func Test_GetBranchesWhenMergedPR(t *testing.T) {
 			assert.Equal(t, false, actual[1].IsLocked)
 			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
+
+		t.Run("not deletable when branch is being rebased", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "rebaseIssue1"},
+				}, nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "rebase", actual[0].Operation)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, "", actual[1].Operation)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("not deletable when branch has stashes", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetStashes("issue1", nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, true, actual[0].HasStashes)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("deletable when stashes are ignored", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetStashes("issue1", nil, conn.NewConf(&conn.Times{N: 0}))
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{IgnoreStashes: true})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, false, actual[0].HasStashes)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+	})
+}
+
+func Test_GetBranchesSearchesEachHostInDeepScan(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	s := conn.Setup(ctrl).
+		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
+		GetMergedBranchNames("@main", nil, nil).
+		GetLog([]conn.LogStub{
+			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
+		}, nil, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
+		GetUncommittedChanges([]conn.UncommittedChangeStub{
+			{Path: "", Output: ""},
+		}, nil, nil).
+		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
+		GetConfig([]conn.ConfigStub{
+			{Key: "branch.main.merge", Filename: "mergeMain"},
+			{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+			{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+			{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+			{Key: "branch.issue1.remote", Filename: "remote"},
+			{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+			{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+		}, nil, nil)
+	s.Conn.EXPECT().
+		GetRepoNames(gomock.Any(), "ghe.example.com", "owner/repo").
+		Return(shared.Repository{Owner: "owner", Name: "repo", DefaultBranchName: "main"}, nil)
+	s.Conn.EXPECT().
+		GetRepoNames(gomock.Any(), "github.com", "oss/repo").
+		Return(shared.Repository{Owner: "oss", Name: "repo", DefaultBranchName: "main"}, nil)
+	s.Conn.EXPECT().
+		GetPullRequests(gomock.Any(), "ghe.example.com", "org:owner", "repo:owner/repo", gomock.Any()).
+		Return([]shared.PullRequest{}, nil)
+	s.Conn.EXPECT().
+		GetPullRequests(gomock.Any(), "github.com", "org:oss", "repo:oss/repo", gomock.Any()).
+		Return(s.ReadPullRequests("issue1Merged"), nil)
+	remotes := []shared.Remote{
+		{Name: "origin", Hostname: "ghe.example.com", RepoName: "owner/repo"},
+		{Name: "upstream", Hostname: "github.com", RepoName: "oss/repo"},
+	}
+
+	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
+
+	assert.Equal(t, 2, len(actual))
+	assert.Equal(t, "issue1", actual[0].Name)
+	assert.Equal(t, shared.Deletable, actual[0].State)
+	assert.Equal(t, "main", actual[1].Name)
+	assert.Equal(t, shared.NotDeletable, actual[1].State)
+}
+
+func Test_GetBranchesVerifiesHeadRepoOfPRsMatchedByName(t *testing.T) {
+	setupDefault := func(s *conn.Stub, remoteFilename string, headRepoName string) *conn.Stub {
+		prs := s.ReadPullRequests("issue1Merged")
+		prs[0].HeadRepoName = headRepoName
+		s.Conn.EXPECT().
+			GetPullRequests(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(prs, nil).
+			AnyTimes()
+		return s.
+			GetRemoteNames("origin_fork", nil, nil).
+			GetSshConfig("github.com", nil, nil).
+			GetRepoNames([]conn.RepoNamesStub{
+				{RepoName: "owner/repo", Filename: "origin"},
+			}, nil, nil).
+			GetBranchNames("@main_issue1", nil, nil).
+			GetStashes("empty", nil, nil).
+			GetReverts("empty", nil, nil).
+			GetBranchProtections("empty", nil, nil).
+			GetMergedBranchNames("@main", nil, nil).
+			GetRefs("main_issue1", nil, nil).
+			GetCommitGraph("issue1", nil, nil).
+			GetLog([]conn.LogStub{
+				{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
+			}, nil, nil).
+			GetUncommittedChanges([]conn.UncommittedChangeStub{
+				{Path: "", Output: ""},
+			}, nil, nil).
+			GetWorktrees("none", nil, nil).
+			GetOperations([]conn.OperationStub{
+				{Path: "", Filename: "none"},
+			}, nil, nil).
+			GetConfig([]conn.ConfigStub{
+				{Key: "remote.origin.gh-resolved", Filename: "empty"},
+				{Key: "remote.fork.gh-resolved", Filename: "empty"},
+				{Key: "branch.main.merge", Filename: "mergeMain"},
+				{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+				{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+				{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+				{Key: "branch.issue1.remote", Filename: remoteFilename},
+				{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+				{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+			}, nil, nil)
+	}
+	t.Run("from scanned repository", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl), "empty", "owner/repo")
+
+		remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Quick)
+
+		actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false, Options{})
+
+		assert.Equal(t, "issue1", actual[0].Name)
+		assert.Equal(t, 1, len(actual[0].PullRequests))
+		assert.Equal(t, shared.Deletable, actual[0].State)
+	})
+
+	t.Run("from upstream remote of branch", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl), "remoteFork", "someone/repo")
+
+		remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Quick)
+
+		actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false, Options{})
+
+		assert.Equal(t, "issue1", actual[0].Name)
+		assert.Equal(t, 1, len(actual[0].PullRequests))
+		assert.Equal(t, shared.Deletable, actual[0].State)
+	})
+
+	t.Run("from unrelated fork", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl), "empty", "stranger/repo")
+
+		remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Quick)
+
+		actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false, Options{})
+
+		assert.Equal(t, "issue1", actual[0].Name)
+		assert.Equal(t, []shared.PullRequest{}, actual[0].PullRequests)
+		assert.Equal(t, shared.NotDeletable, actual[0].State)
+	})
+	t.Run("from deleted head repository", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		s := setupDefault(conn.Setup(ctrl), "empty", "")
+		remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Quick)
+
+		actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false, Options{})
+
+		assert.Equal(t, "issue1", actual[0].Name)
+		assert.Equal(t, 1, len(actual[0].PullRequests))
+		assert.Equal(t, shared.Deletable, actual[0].State)
 	})
 }
 
@@ -262,19 +493,23 @@ func Test_GetBranchesWhenSquashAndMergedPR(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("issue1Merged", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -294,7 +529,7 @@ func Test_GetBranchesWhenSquashAndMergedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -311,7 +546,7 @@ func Test_GetBranchesWhenSquashAndMergedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, true)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, true, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -340,19 +575,23 @@ func Test_GetBranchesWhenSquashAndMergedPRByUpstream(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin_upstream"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("issue1UpMerged", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -372,7 +611,7 @@ func Test_GetBranchesWhenSquashAndMergedPRByUpstream(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -386,13 +625,14 @@ func Test_GetBranchesWhenSquashAndMergedPRByUpstream(t *testing.T) {
 			defer ctrl.Finish()
 			s := conn.Setup(ctrl).
 				GetBranchNames("@main_forkMain", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "fork/main", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "forkMain"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_forkMain"},
-				}, nil, nil).
+				GetRefs("main_forkMain", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("forkMainUpMerged", nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "branch.fork/main.merge", Filename: "mergeForkMain"},
@@ -403,7 +643,7 @@ func Test_GetBranchesWhenSquashAndMergedPRByUpstream(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "fork/main", actual[0].Name)
@@ -432,19 +672,23 @@ func Test_GetBranchesWhenMergedPRWithDefaultBranchAsHeadRef(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("mainMerged", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -464,7 +708,7 @@ func Test_GetBranchesWhenMergedPRWithDefaultBranchAsHeadRef(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -493,21 +737,90 @@ func Test_GetBranchesWhenSquashAndMergedToOriginAndMissingDefaultBranch(t *testi
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("empty", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "issue1_originMain"},
+				GetRefs("issue1_originMain", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: ""},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil).
+				FetchBranch(nil, nil).
+				CheckoutBranch(nil, nil)
+		}
+
+		t.Run("deletable when default branch does not exists", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "(HEAD detached at origin/main)", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "issue1", actual[1].Name)
+			assert.Equal(t, shared.Deletable, actual[1].State)
+		})
+	})
+}
+
+/*
+// Before
+// upstream/main : *-------*
+//                  \   ../
+// topic         :   *---* (PR merged)
+*/
+func Test_GetBranchesWhenSquashAndMergedToUpstreamAndMissingDefaultBranch(t *testing.T) {
+	t.Run("with deep scan", func(t *testing.T) {
+		scan := shared.Deep
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin_upstream", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("empty", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
+				GetRefs("issue1_originMain", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("issue1Merged", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "remote.upstream.gh-resolved", Filename: "ghResolved"},
 					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
 					{Key: "branch.issue1.remote", Filename: "remote"},
 					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
@@ -517,94 +830,662 @@ func Test_GetBranchesWhenSquashAndMergedToOriginAndMissingDefaultBranch(t *testi
 				CheckoutBranch(nil, nil)
 		}
 
-		t.Run("deletable when default branch does not exists", func(t *testing.T) {
+		t.Run("deletable when default branch does not exists", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "(HEAD detached at upstream/main)", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "issue1", actual[1].Name)
+			assert.Equal(t, shared.Deletable, actual[1].State)
+		})
+
+		t.Run("returns error when operation is in progress in current worktree", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "merge"},
+				}, nil, nil).
+				FetchBranch(nil, conn.NewConf(&conn.Times{N: 0})).
+				CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 0}))
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.ErrorContains(t, err, "merge is in progress")
+		})
+	})
+}
+
+/*
+// Before:
+// main  : *---*---*
+//          \   ../
+// topic :   *---* (PR merged) ---+
+*/
+func Test_GetBranchesWhenSquashAndMergedPRWithChanges(t *testing.T) {
+	t.Run("with deep scan", func(t *testing.T) {
+		scan := shared.Deep
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("main_@issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("main", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
+				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: " M README.md"},
+					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_basic", Output: ""},
+				}, nil, nil).
+				GetSubmodules([]conn.SubmoduleStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil).
+				CheckoutBranch(nil, nil)
+		}
+
+		t.Run("not deletable with uncommitted changes", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.False(t, actual[0].HasModifiedSubmodules)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("not deletable with modified submodules", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: " M libs/lib"},
+				}, nil, nil).
+				GetSubmodules([]conn.SubmoduleStub{
+					{Path: "", Filename: "lib"},
+				}, nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.True(t, actual[0].HasModifiedSubmodules)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("not deletable with uncommitted changes when submodules cannot be read", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: " M libs/lib"},
+				}, nil, nil).
+				GetSubmodules([]conn.SubmoduleStub{
+					{Path: "", Filename: "none"},
+				}, ErrCommand, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Nil(t, err)
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.False(t, actual[0].HasModifiedSubmodules)
+		})
+
+		t.Run("deletable with untracked files", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: "?? new.txt"},
+				}, nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+	})
+}
+
+/*
+// Before
+// main  : *---*---*
+//          \   ../
+// topic :   *---* (PR merged) ---*
+*/
+func Test_GetBranchesWhenMergedPRWithNotFullyMerged(t *testing.T) {
+	t.Run("with deep scan", func(t *testing.T) {
+		scan := shared.Deep
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main_issue1SquashAndMerged"}, {BranchName: "issue1", Filename: "issue1CommitAfterMerge"},
+				}, nil, nil).
+				GetRefs("main_issue1CommitAfterMerge", nil, nil).
+				GetCommitGraph("issue1CommitAfterMerge", nil, nil).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetPatchIds("issue1CommitAfterMerge", nil, nil).
+				GetCommitPatchIds("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: ""},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil)
+		}
+
+		t.Run("not deletable", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("deletable after salvaging commits added after merge", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl)
+			gomock.InOrder(
+				s.Conn.EXPECT().CreateBranch(gomock.Any(), "issue1-followup", "issue1").Return("", nil),
+				s.Conn.EXPECT().RebaseBranch(gomock.Any(), "issue1-followup", "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", "origin/main").Return("", nil),
+				s.Conn.EXPECT().CheckoutBranch(gomock.Any(), "main", false).Return("", nil),
+			)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{Salvage: true})
+
+			assert.Equal(t, 3, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "issue1-followup", actual[0].FollowupBranchName)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "issue1-followup", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+			assert.Equal(t, "main", actual[2].Name)
+			assert.Equal(t, shared.NotDeletable, actual[2].State)
+		})
+
+		t.Run("not deletable when salvaged commits conflict", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				CreateBranch(nil, conn.NewConf(&conn.Times{N: 1})).
+				RebaseBranch(ErrCommand, conn.NewConf(&conn.Times{N: 1})).
+				AbortRebase(nil, conn.NewConf(&conn.Times{N: 1})).
+				CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 1})).
+				DeleteBranches(nil, conn.NewConf(&conn.Times{N: 1}))
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{Salvage: true})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "", actual[0].FollowupBranchName)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+	})
+}
+
+/*
+// Before
+// main  : *---*---*
+//          \
+// topic :   *---* (PR merged, then rebased or amended locally)
+*/
+func Test_GetBranchesWhenMergedPRIsRewrittenLocally(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1Rebased"},
+				}, nil, nil).
+				GetTree("empty", nil, nil).
+				GetPatchIds("empty", nil, nil).
+				GetCommitPatchIds("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: ""},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil)
+		}
+
+		t.Run("deletable when patch-ids match", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetPatchIds("issue1Rebased", nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "patch-id", actual[0].PullRequests[0].MatchedBy)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("deletable when trees match", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetPullRequests("issue1AmendedMerged", nil, nil).
+				GetTree("issue1", nil, nil).
+				GetPatchIds("empty", nil, conn.NewConf(&conn.Times{N: 0}))
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "tree", actual[0].PullRequests[0].MatchedBy)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("not deletable when content differs", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetPullRequests("issue1Merged", nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "", actual[0].PullRequests[0].MatchedBy)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("returns error when the tree cannot be read", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetPullRequests("issue1AmendedMerged", nil, nil).
+				GetTree("empty", ErrCommand, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.ErrorIs(t, err, ErrCommand)
+		})
+
+		t.Run("returns error when patch-ids cannot be read", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetPatchIds("empty", ErrCommand, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.ErrorIs(t, err, ErrCommand)
+		})
+	})
+}
+
+func Test_GetBranchesWhenMergedPRIsReverted(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
+				}, nil, nil).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: ""},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil)
+		}
+
+		t.Run("not deletable when the merge is reverted", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetReverts("issue1Reverted", nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.True(t, actual[0].PullRequests[0].IsReverted)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
+		t.Run("deletable when the revert is reverted", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetReverts("issue1Reapplied", nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.False(t, actual[0].PullRequests[0].IsReverted)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+		})
+
+		t.Run("returns error when reverts cannot be read", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetReverts("empty", ErrCommand, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.ErrorIs(t, err, ErrCommand)
+		})
+	})
+}
+
+/*
+// main   : A---B (issue1 squash merged)
+//           \
+// issue1 :   C
+//             \
+// issue2 :     D (created from issue1 without an upstream)
+*/
+func Test_GetBranchesWhenBranchIsStackedWithoutUpstream(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
+
+		setupDefault := func(s *conn.Stub) *conn.Stub {
+			return s.
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@main_issue1_issue2", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1_issue2", nil, nil).
+				GetCommitGraph("issue1_issue2", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"}, {BranchName: "issue2", Filename: "issue2"},
+				}, nil, nil).
+				GetPullRequests("issue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "", Output: ""},
+				}, nil, nil).
+				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue1.remote", Filename: "remote"},
+					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.issue2.merge", Filename: "empty"},
+					{Key: "branch.issue2.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.issue2.gh-poi-protected", Filename: "empty"},
+				}, nil, nil)
+		}
+
+		t.Run("not deletable when other branch is built on it", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			s := conn.Setup(ctrl)
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
-			assert.Equal(t, 2, len(actual))
-			assert.Equal(t, "(HEAD detached at origin/main)", actual[0].Name)
+			assert.Equal(t, 3, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, []string{"issue2"}, actual[0].StackedBranchNames)
 			assert.Equal(t, shared.NotDeletable, actual[0].State)
-			assert.Equal(t, "issue1", actual[1].Name)
-			assert.Equal(t, shared.Deletable, actual[1].State)
+			assert.Equal(t, "issue2", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
 	})
 }
 
-/*
-// Before
-// upstream/main : *-------*
-//                  \   ../
-// topic         :   *---* (PR merged)
-*/
-func Test_GetBranchesWhenSquashAndMergedToUpstreamAndMissingDefaultBranch(t *testing.T) {
-	t.Run("with deep scan", func(t *testing.T) {
-		scan := shared.Deep
+func Test_GetBranchesWhenBranchIsProtectedOnGitHub(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
 
 		setupDefault := func(s *conn.Stub) *conn.Stub {
 			return s.
-				GetRemoteNames("origin_upstream", nil, nil).
+				GetRemoteNames("origin", nil, nil).
 				GetSshConfig("github.com", nil, nil).
 				GetRepoNames([]conn.RepoNamesStub{
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
-				GetBranchNames("@issue1", nil, nil).
-				GetMergedBranchNames("empty", nil, nil).
+				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetLog([]conn.LogStub{
-					{BranchName: "issue1", Filename: "issue1"},
-				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "issue1_originMain"},
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
 				GetPullRequests("issue1Merged", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
-					{Key: "remote.upstream.gh-resolved", Filename: "ghResolved"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
 					{Key: "branch.issue1.merge", Filename: "mergeIssue1"},
 					{Key: "branch.issue1.remote", Filename: "remote"},
 					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
 					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
-				}, nil, nil).
-				FetchBranch(nil, nil).
-				CheckoutBranch(nil, nil)
+				}, nil, nil)
 		}
 
-		t.Run("deletable when default branch does not exists", func(t *testing.T) {
+		t.Run("not deletable when a ruleset matches", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			s := conn.Setup(ctrl)
+			s := conn.Setup(ctrl).
+				GetBranchProtections("issue1", nil, nil)
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
-			assert.Equal(t, "(HEAD detached at upstream/main)", actual[0].Name)
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.True(t, actual[0].IsProtectedOnGitHub)
 			assert.Equal(t, shared.NotDeletable, actual[0].State)
-			assert.Equal(t, "issue1", actual[1].Name)
-			assert.Equal(t, shared.Deletable, actual[1].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.False(t, actual[1].IsProtectedOnGitHub)
+		})
+
+		t.Run("deletable when the rules cannot be read", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetBranchProtections("empty", ErrCommand, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, shared.Deletable, actual[0].State)
 		})
 	})
 }
 
-/*
-// Before:
-// main  : *---*---*
-//          \   ../
-// topic :   *---* (PR merged) ---+
-*/
-func Test_GetBranchesWhenSquashAndMergedPRWithChanges(t *testing.T) {
-	t.Run("with deep scan", func(t *testing.T) {
-		scan := shared.Deep
+func Test_GetBranchesWhenPRIsMergedIntoAnotherBranch(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
 
 		setupDefault := func(s *conn.Stub) *conn.Stub {
 			return s.
@@ -613,21 +1494,24 @@ func Test_GetBranchesWhenSquashAndMergedPRWithChanges(t *testing.T) {
 				GetRepoNames([]conn.RepoNamesStub{
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
-				GetBranchNames("main_@issue1", nil, nil).
-				GetMergedBranchNames("main", nil, nil).
+				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
-				GetPullRequests("issue1Merged", nil, nil).
+				GetPullRequests("issue1MergedIntoRelease", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
-					{Path: "", Output: " M README.md"},
-					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_basic", Output: ""},
+					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -637,56 +1521,57 @@ func Test_GetBranchesWhenSquashAndMergedPRWithChanges(t *testing.T) {
 					{Key: "branch.issue1.remote", Filename: "remote"},
 					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
 					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
-				}, nil, nil).
-				CheckoutBranch(nil, nil)
+				}, nil, nil)
 		}
 
-		t.Run("not deletable with uncommitted changes", func(t *testing.T) {
+		t.Run("not deletable when the base branch is not an integration branch", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			s := conn.Setup(ctrl)
-			setupDefault(s)
+			s := setupDefault(conn.Setup(ctrl))
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
+			assert.True(t, actual[0].PullRequests[0].IsMergedElsewhere)
 			assert.Equal(t, shared.NotDeletable, actual[0].State)
 			assert.Equal(t, "main", actual[1].Name)
 			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
 
-		t.Run("deletable with untracked files", func(t *testing.T) {
+		t.Run("deletable when the base branch matches an integration branch", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			s := conn.Setup(ctrl).
-				GetUncommittedChanges([]conn.UncommittedChangeStub{
-					{Path: "", Output: "?? new.txt"},
-				}, nil, nil)
-			setupDefault(s)
+			s := setupDefault(conn.Setup(ctrl))
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{IntegrationBranches: []string{"release/*"}})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.False(t, actual[0].PullRequests[0].IsMergedElsewhere)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+		})
+
+		t.Run("deletable when closed PRs are deleted", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := setupDefault(conn.Setup(ctrl))
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Closed, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
 			assert.Equal(t, shared.Deletable, actual[0].State)
-			assert.Equal(t, "main", actual[1].Name)
-			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
 	})
 }
 
-/*
-// Before
-// main  : *---*---*
-//          \   ../
-// topic :   *---* (PR merged) ---*
-*/
-func Test_GetBranchesWhenMergedPRWithNotFullyMerged(t *testing.T) {
-	t.Run("with deep scan", func(t *testing.T) {
-		scan := shared.Deep
+func Test_GetBranchesWhenPRHasKeepLabel(t *testing.T) {
+	t.Run("with quick scan", func(t *testing.T) {
+		scan := shared.Quick
 
 		setupDefault := func(s *conn.Stub) *conn.Stub {
 			return s.
@@ -696,21 +1581,23 @@ func Test_GetBranchesWhenMergedPRWithNotFullyMerged(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetLog([]conn.LogStub{
-					{BranchName: "main", Filename: "main_issue1SquashAndMerged"}, {BranchName: "issue1", Filename: "issue1CommitAfterMerge"},
-				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "cb197ba87e4ad323b1008c611212deb7da2a4a49", Filename: "main"},
-					{Oid: "b8a2645298053fb62ea03e27feea6c483d3fd27e", Filename: "issue1"},
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
+					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetPullRequests("issue1Merged", nil, nil).
+				GetPullRequests("issue1MergedWithLabels", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -723,21 +1610,36 @@ func Test_GetBranchesWhenMergedPRWithNotFullyMerged(t *testing.T) {
 				}, nil, nil)
 		}
 
-		t.Run("not deletable", func(t *testing.T) {
+		t.Run("not deletable when the PR has a keep label", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
-			s := conn.Setup(ctrl)
-			setupDefault(s)
+			s := setupDefault(conn.Setup(ctrl))
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{KeepLabels: []string{"backport-pending"}})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, "Backport-Pending", actual[0].PullRequests[0].KeepLabel)
 			assert.Equal(t, shared.NotDeletable, actual[0].State)
 			assert.Equal(t, "main", actual[1].Name)
 			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
+
+		t.Run("deletable when no keep label is configured", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := setupDefault(conn.Setup(ctrl))
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, []string{"enhancement", "Backport-Pending"}, actual[0].PullRequests[0].Labels)
+			assert.Equal(t, "", actual[0].PullRequests[0].KeepLabel)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+		})
 	})
 }
 
@@ -759,19 +1661,23 @@ func Test_GetBranchesWhenClosedPR(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("issue1Closed", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -791,7 +1697,7 @@ func Test_GetBranchesWhenClosedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -807,7 +1713,7 @@ func Test_GetBranchesWhenClosedPR(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Closed, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Closed, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -836,19 +1742,23 @@ func Test_GetBranchesWhenClosedAndMergedPRs(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_issue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 				}, nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
+				GetRefs("main_issue1", nil, nil).
+				GetCommitGraph("issue1", nil, nil).
 				GetPullRequests("issue1Merged_issue1Closed", nil, nil).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "", Output: ""},
 				}, nil, nil).
 				GetWorktrees("none", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -868,7 +1778,7 @@ func Test_GetBranchesWhenClosedAndMergedPRs(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -884,7 +1794,7 @@ func Test_GetBranchesWhenClosedAndMergedPRs(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Closed, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Closed, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -913,6 +1823,9 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@main_linkedIssue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("main_@linkedIssue1", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "main", Filename: "main_issue1Merged"}, {BranchName: "linkedIssue1", Filename: "issue1Merged"},
@@ -924,6 +1837,9 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Output: ""},
 				}, nil, nil).
 				GetWorktrees("@main_+linkedIssue1", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -944,7 +1860,7 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "linkedIssue1", actual[0].Name)
@@ -953,6 +1869,26 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
 
+		t.Run("not deletable when merge is in progress in linked worktree", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetOperations([]conn.OperationStub{
+					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Filename: "merge"},
+				}, nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "linkedIssue1", actual[0].Name)
+			assert.Equal(t, "merge", actual[0].Operation)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
 		t.Run("not deletable when HEAD is linked worktree", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
@@ -961,7 +1897,7 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "linkedIssue1", actual[0].Name)
@@ -976,11 +1912,14 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			s := conn.Setup(ctrl).
 				GetUncommittedChanges([]conn.UncommittedChangeStub{
 					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Output: " M README.md"},
+				}, nil, nil).
+				GetSubmodules([]conn.SubmoduleStub{
+					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Filename: "none"},
 				}, nil, nil)
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "linkedIssue1", actual[0].Name)
@@ -999,7 +1938,7 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "linkedIssue1", actual[0].Name)
@@ -1013,16 +1952,20 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			defer ctrl.Finish()
 			s := conn.Setup(ctrl).
 				GetBranchNames("@main_linkedIssue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main_linkedIssue1", nil, nil).
-				GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-					{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-					{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-				}, nil, nil).
-				GetWorktrees("locked", nil, nil)
+				GetRefs("main_linkedIssue1Merged", nil, nil).
+				GetCommitGraph("empty", nil, nil).
+				GetWorktrees("locked", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil)
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "linkedIssue1", actual[0].Name)
@@ -1030,6 +1973,53 @@ func Test_GetBranchesWhenMergedPRIsLinkedWorktree(t *testing.T) {
 			assert.Equal(t, "main", actual[1].Name)
 			assert.Equal(t, shared.NotDeletable, actual[1].State)
 		})
+
+		t.Run("not deletable when HEAD of bare repository", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetRemoteNames("origin", nil, nil).
+				GetSshConfig("github.com", nil, nil).
+				GetRepoNames([]conn.RepoNamesStub{
+					{RepoName: "owner/repo", Filename: "origin"},
+				}, nil, nil).
+				GetBranchNames("@main_linkedIssue1", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
+				GetMergedBranchNames("main_@linkedIssue1", nil, nil).
+				GetLog([]conn.LogStub{
+					{BranchName: "main", Filename: "main_issue1Merged"}, {BranchName: "linkedIssue1", Filename: "issue1Merged"},
+				}, nil, nil).
+				GetPullRequests("linkedIssue1Merged", nil, nil).
+				GetUncommittedChanges([]conn.UncommittedChangeStub{
+					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Output: ""},
+				}, nil, nil).
+				GetWorktrees("bare_+linkedIssue1", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
+				GetConfig([]conn.ConfigStub{
+					{Key: "remote.origin.gh-resolved", Filename: "empty"},
+					{Key: "branch.main.merge", Filename: "mergeMain"},
+					{Key: "branch.main.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.main.gh-poi-protected", Filename: "empty"},
+					{Key: "branch.linkedIssue1.merge", Filename: "mergeIssue1"},
+					{Key: "branch.linkedIssue1.gh-poi-locked", Filename: "empty"},
+					{Key: "branch.linkedIssue1.gh-poi-protected", Filename: "empty"},
+				}, nil, nil).
+				CheckoutBranch(nil, conn.NewConf(&conn.Times{N: 0}))
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "linkedIssue1", actual[0].Name)
+			assert.Equal(t, shared.Deletable, actual[0].State)
+			assert.Equal(t, "main", actual[1].Name)
+			assert.True(t, actual[1].Worktree.IsBare)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
 	})
 }
 
@@ -1053,7 +2043,12 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 					{RepoName: "owner/repo", Filename: "origin"},
 				}, nil, nil).
 				GetBranchNames("@issue1_issue2", nil, nil).
+				GetStashes("empty", nil, nil).
+				GetReverts("empty", nil, nil).
+				GetBranchProtections("empty", nil, nil).
 				GetMergedBranchNames("@main_issue1", nil, nil).
+				GetRefs("main_issue1Merged", nil, nil).
+				GetCommitGraph("empty", nil, nil).
 				GetLog([]conn.LogStub{
 					{BranchName: "issue1", Filename: "issue1Merged"}, {BranchName: "issue2", Filename: "issue1"},
 				}, nil, nil).
@@ -1064,12 +2059,16 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 					{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Output: ""},
 				}, nil, nil).
 				GetWorktrees("@mainIssue1_+linkedIssue2", nil, nil).
+				GetOperations([]conn.OperationStub{
+					{Path: "", Filename: "none"},
+				}, nil, nil).
 				GetConfig([]conn.ConfigStub{
 					{Key: "remote.origin.gh-resolved", Filename: "empty"},
 					{Key: "branch.issue1.merge", Filename: "mergeMain"},
 					{Key: "branch.issue1.gh-poi-locked", Filename: "empty"},
 					{Key: "branch.issue1.gh-poi-protected", Filename: "empty"},
 					{Key: "branch.issue2.merge", Filename: "mergeIssue1"},
+					{Key: "branch.issue2.remote", Filename: "remote"},
 					{Key: "branch.issue2.gh-poi-locked", Filename: "empty"},
 					{Key: "branch.issue2.gh-poi-protected", Filename: "empty"},
 				}, nil, nil).
@@ -1084,7 +2083,7 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 3, len(actual))
 			assert.Equal(t, "(HEAD detached at origin/main)", actual[0].Name)
@@ -1095,6 +2094,26 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 			assert.Equal(t, shared.NotDeletable, actual[2].State)
 		})
 
+		t.Run("not deletable when other branch is stacked on it", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := conn.Setup(ctrl).
+				GetConfig([]conn.ConfigStub{
+					{Key: "branch.issue2.remote", Filename: "local"},
+				}, nil, nil)
+			setupDefault(s)
+			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
+
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
+
+			assert.Equal(t, 2, len(actual))
+			assert.Equal(t, "issue1", actual[0].Name)
+			assert.Equal(t, []string{"issue2"}, actual[0].StackedBranchNames)
+			assert.Equal(t, shared.NotDeletable, actual[0].State)
+			assert.Equal(t, "issue2", actual[1].Name)
+			assert.Equal(t, shared.NotDeletable, actual[1].State)
+		})
+
 		t.Run("not deletable when HEAD is not main worktree", func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
@@ -1103,7 +2122,7 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 2, len(actual))
 			assert.Equal(t, "issue1", actual[0].Name)
@@ -1122,7 +2141,7 @@ func Test_GetBranchesWhenMergedPRIsMainWorktree(t *testing.T) {
 			setupDefault(s)
 			remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, scan)
 
-			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false)
+			actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, scan, false, Options{})
 
 			assert.Equal(t, 3, len(actual))
 			assert.Equal(t, "(HEAD detached at origin/main)", actual[0].Name)
@@ -1148,6 +2167,9 @@ func Test_BranchIsNotDeletableWhenFirstCommitOfTopicBranchIsAssociatedWithDefaul
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "main"},
@@ -1157,6 +2179,9 @@ func Test_BranchIsNotDeletableWhenFirstCommitOfTopicBranchIsAssociatedWithDefaul
 			{Path: "", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1169,7 +2194,7 @@ func Test_BranchIsNotDeletableWhenFirstCommitOfTopicBranchIsAssociatedWithDefaul
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Quick)
 
-	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false)
+	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Quick, false, Options{})
 
 	assert.Equal(t, 2, len(actual))
 	assert.Equal(t, "issue1", actual[0].Name)
@@ -1189,21 +2214,24 @@ func Test_BranchesAndPRsAreNotAssociatedWhenManyLocalCommitsAreAhead(t *testing.
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"},
 			{BranchName: "issue1", Filename: "issue1ManyCommits"}, // return with '--max-count=3'
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "62d5d8280031f607f1db058da959a97f6a8e6d90", Filename: "issue1"},
-			{Oid: "b8a2645298053fb62ea03e27feea6c483d3fd27e", Filename: "issue1"},
-			{Oid: "d787669ee4a103fe0b361fe31c10ea037c72f27c", Filename: "issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1ManyCommits", nil, nil).
+		GetCommitGraph("issue1ManyCommits", nil, nil).
 		GetPullRequests("notFound", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1216,16 +2244,157 @@ func Test_BranchesAndPRsAreNotAssociatedWhenManyLocalCommitsAreAhead(t *testing.
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{Depth: 3})
 
 	assert.Equal(t, 2, len(actual))
 	assert.Equal(t, "issue1", actual[0].Name)
 	assert.Equal(t, []shared.PullRequest{}, actual[0].PullRequests)
+	assert.True(t, actual[0].IsTruncated)
 	assert.Equal(t, shared.NotDeletable, actual[0].State)
 	assert.Equal(t, "main", actual[1].Name)
 	assert.Equal(t, shared.NotDeletable, actual[1].State)
 }
 
+func Test_HistoryDepthDefaultsToDistanceFromDefaultBranch(t *testing.T) {
+	main := shared.Ref{Name: "refs/heads/main", Oid: "A"}
+	issue1 := shared.Ref{Name: "refs/heads/issue1", Oid: "C"}
+	graph := shared.NewCommitGraph(
+		[]shared.Ref{issue1, main},
+		[]shared.Ref{main},
+		[][]string{{"C", "B"}, {"B", "A"}},
+	)
+
+	assert.Equal(t, 3, historyDepth(shared.Branch{Name: "issue1"}, 0, graph))
+	assert.Equal(t, 5, historyDepth(shared.Branch{Name: "issue1"}, 5, graph))
+	assert.Equal(t, defaultHistoryDepth, historyDepth(shared.Branch{Name: "unknown"}, 0, graph))
+}
+
+/*
+// main   : A---B
+//             \
+// issue1 :     C---D
+//                   \
+// issue2 :           E
+*/
+func Test_GetChildBranchNames(t *testing.T) {
+	main := shared.Ref{Name: "refs/heads/main", Oid: "B"}
+	issue1 := shared.Ref{Name: "refs/heads/issue1", Oid: "D"}
+	issue1Backup := shared.Ref{Name: "refs/heads/issue1-backup", Oid: "D"}
+	issue2 := shared.Ref{Name: "refs/heads/issue2", Oid: "E"}
+	originIssue3 := shared.Ref{Name: "refs/remotes/origin/issue3", Oid: "E"}
+	graph := shared.NewCommitGraph(
+		[]shared.Ref{issue1, issue1Backup, issue2, main, originIssue3},
+		[]shared.Ref{main},
+		[][]string{{"E", "D"}, {"D", "C"}, {"C", "B"}},
+	)
+
+	assert.Equal(t, []string{"issue2"}, getChildBranchNames("D", shared.Branch{Name: "issue1"}, "main", []string{}, graph))
+	assert.Equal(t, []string{"issue2"}, getChildBranchNames("D", shared.Branch{Name: "issue1-backup"}, "main", []string{}, graph))
+	assert.Equal(t, []string{}, getChildBranchNames("E", shared.Branch{Name: "issue2"}, "main", []string{}, graph))
+}
+
+/*
+// main    : A---B
+//                \
+// develop :       C---D---F
+//                      \ /
+// issue1  :             E
+*/
+func Test_TrimBranchStopsAtTrunkBranches(t *testing.T) {
+	main := shared.Ref{Name: "refs/heads/main", Oid: "B"}
+	develop := shared.Ref{Name: "refs/heads/develop", Oid: "F"}
+	issue1 := shared.Ref{Name: "refs/heads/issue1", Oid: "E"}
+	graph := shared.NewCommitGraph(
+		[]shared.Ref{develop, issue1, main},
+		[]shared.Ref{main},
+		[][]string{{"F", "D", "E"}, {"E", "D"}, {"D", "C"}, {"C", "B"}},
+	)
+	branch := shared.Branch{Name: "issue1"}
+
+	assert.Equal(t, []string{"E"}, trimBranch([]string{"E", "D", "C"}, branch, "main", []string{"develop"}, graph))
+	assert.Equal(t, []string{}, getChildBranchNames("E", branch, "main", []string{"develop"}, graph))
+	// Without the trunk, develop is taken for a branch stacked on issue1 and its commits leak into the history
+	assert.Equal(t, []string{"E", "D", "C"}, trimBranch([]string{"E", "D", "C"}, branch, "main", []string{}, graph))
+	assert.Equal(t, []string{"develop"}, getChildBranchNames("E", branch, "main", []string{}, graph))
+}
+
+func Test_GetDirectChildNames(t *testing.T) {
+	branches := []shared.Branch{
+		{Name: "issue1", StackedBranchNames: []string{"issue2", "issue3", "issue4"}},
+		{Name: "issue2", StackedBranchNames: []string{"issue3"}},
+		{Name: "issue3"},
+		{Name: "issue4"},
+	}
+
+	assert.Equal(t, []string{"issue2", "issue4"}, GetDirectChildNames(branches[0], branches))
+	assert.Equal(t, []string{"issue3"}, GetDirectChildNames(branches[1], branches))
+	assert.Equal(t, []string{}, GetDirectChildNames(branches[2], branches))
+}
+
+func Test_FindDeletedBase(t *testing.T) {
+	prs := []shared.PullRequest{
+		{Name: "issue1", State: shared.Closed, Commits: []string{"B"}},
+		{Name: "issue1", State: shared.Merged, Commits: []string{"B", "C"}},
+	}
+	branch := shared.Branch{Name: "issue2", Commits: []string{"D", "C", "B"}}
+
+	assert.Equal(t, &shared.DeletedBase{Name: "issue1", Oid: "C"}, findDeletedBase(branch, "issue1", prs))
+	assert.Nil(t, findDeletedBase(branch, "issue3", prs))
+	assert.Nil(t, findDeletedBase(branch, "issue1", prs[:1]))
+}
+
+func Test_ApplyBaseBranchesComparesWithDefaultBranchOfBaseRepository(t *testing.T) {
+	pr := func(baseRefName string, baseRepoName string) shared.PullRequest {
+		return shared.PullRequest{State: shared.Merged, BaseRefName: baseRefName, BaseRepoName: baseRepoName}
+	}
+	// The fork still has the default branch the upstream was renamed from
+	defaultBranchNames := map[string]string{"owner/repo": "master", "parent-owner/repo": "main"}
+	branches := []shared.Branch{{Name: "issue1", PullRequests: []shared.PullRequest{
+		pr("main", "parent-owner/repo"),
+		pr("main", "owner/repo"),
+		pr("master", "other-owner/repo"),
+		pr("", "parent-owner/repo"),
+	}}}
+
+	actual := applyBaseBranches(branches, "master", defaultBranchNames, []string{})
+
+	assert.False(t, actual[0].PullRequests[0].IsMergedElsewhere)
+	assert.True(t, actual[0].PullRequests[1].IsMergedElsewhere)
+	assert.False(t, actual[0].PullRequests[2].IsMergedElsewhere)
+	assert.False(t, actual[0].PullRequests[3].IsMergedElsewhere)
+}
+
+func Test_CheckDeletionKeepsBranchesUnderKeptStackedBranches(t *testing.T) {
+	mergedBranch := func(name string, stackedBranchNames ...string) shared.Branch {
+		return shared.Branch{
+			Name:               name,
+			Commits:            []string{name},
+			PullRequests:       []shared.PullRequest{{State: shared.Merged, Commits: []string{name}}},
+			StackedBranchNames: stackedBranchNames,
+		}
+	}
+
+	t.Run("all deletable when whole stack is merged", func(t *testing.T) {
+		actual := checkDeletion([]shared.Branch{
+			mergedBranch("issue1", "issue2"), mergedBranch("issue2", "issue3"), mergedBranch("issue3"),
+		}, shared.Merged)
+
+		assert.Equal(t, shared.Deletable, actual[0].State)
+		assert.Equal(t, shared.Deletable, actual[1].State)
+		assert.Equal(t, shared.Deletable, actual[2].State)
+	})
+
+	t.Run("kept through stack when top is not merged", func(t *testing.T) {
+		actual := checkDeletion([]shared.Branch{
+			mergedBranch("issue1", "issue2"), mergedBranch("issue2", "issue3"), {Name: "issue3"},
+		}, shared.Merged)
+
+		assert.Equal(t, shared.NotDeletable, actual[0].State)
+		assert.Equal(t, shared.NotDeletable, actual[1].State)
+		assert.Equal(t, shared.NotDeletable, actual[2].State)
+	})
+}
+
 func Test_NoCommitHistoryWhenFirstCommitOfTopicBranchIsAssociatedWithDefaultBranch(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1237,18 +2406,23 @@ func Test_NoCommitHistoryWhenFirstCommitOfTopicBranchIsAssociatedWithDefaultBran
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "main"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1OnMain", nil, nil).
+		GetCommitGraph("empty", nil, nil).
 		GetPullRequests("notFound", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1261,7 +2435,7 @@ func Test_NoCommitHistoryWhenFirstCommitOfTopicBranchIsAssociatedWithDefaultBran
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.Equal(t, 2, len(actual))
 	assert.Equal(t, "issue1", actual[0].Name)
@@ -1282,19 +2456,24 @@ func Test_NoCommitHistoryWhenDetachedBranch(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("main_@detached", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main", nil, nil).
+		GetCommitGraph("empty", nil, nil).
 		GetPullRequests("notFound", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 			{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_basic", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1305,7 +2484,7 @@ func Test_NoCommitHistoryWhenDetachedBranch(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	actual, _ := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.Equal(t, 2, len(actual))
 	assert.Equal(t, "(HEAD detached at a97e963)", actual[0].Name)
@@ -1338,19 +2517,23 @@ func Test_DoesNotReturnErrorWhenGetSshConfigFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
 		GetPullRequests("issue1Merged", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1363,7 +2546,7 @@ func Test_DoesNotReturnErrorWhenGetSshConfigFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.Nil(t, err)
 }
@@ -1383,7 +2566,7 @@ func Test_ReturnsErrorWhenGetRepoNamesFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1404,7 +2587,7 @@ func Test_ReturnsErrorWhenGetBranchNamesFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1420,13 +2603,16 @@ func Test_ReturnsErrorWhenGetMergedBranchNamesFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", ErrCommand, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1442,10 +2628,15 @@ func Test_ReturnsErrorWhenGetLogFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, ErrCommand, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.gh-poi-locked", Filename: "empty"},
@@ -1456,12 +2647,12 @@ func Test_ReturnsErrorWhenGetLogFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
 
-func Test_ReturnsErrorWhenGetAssociatedRefNamesFails(t *testing.T) {
+func Test_ReturnsErrorWhenGetCommitGraphFails(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -1472,14 +2663,15 @@ func Test_ReturnsErrorWhenGetAssociatedRefNamesFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, ErrCommand, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", ErrCommand, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.gh-poi-locked", Filename: "empty"},
@@ -1490,7 +2682,7 @@ func Test_ReturnsErrorWhenGetAssociatedRefNamesFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1506,19 +2698,23 @@ func Test_ReturnsErrorWhenGetPullRequestsFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
 		GetPullRequests("issue1Merged", ErrCommand, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.gh-poi-locked", Filename: "empty"},
@@ -1529,7 +2725,7 @@ func Test_ReturnsErrorWhenGetPullRequestsFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1545,19 +2741,23 @@ func Test_ReturnsErrorWhenGetUncommittedChangesFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("@main_issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("@main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
 		GetPullRequests("issue1Merged", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 		}, ErrCommand, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
 			{Key: "branch.main.merge", Filename: "mergeMain"},
@@ -1570,7 +2770,7 @@ func Test_ReturnsErrorWhenGetUncommittedChangesFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1586,20 +2786,24 @@ func Test_ReturnsErrorWhenCheckoutBranchFails(t *testing.T) {
 			{RepoName: "owner/repo", Filename: "origin"},
 		}, nil, nil).
 		GetBranchNames("main_@issue1", nil, nil).
+		GetStashes("empty", nil, nil).
+		GetReverts("empty", nil, nil).
+		GetBranchProtections("empty", nil, nil).
 		GetMergedBranchNames("main", nil, nil).
 		GetLog([]conn.LogStub{
 			{BranchName: "main", Filename: "main"}, {BranchName: "issue1", Filename: "issue1"},
 		}, nil, nil).
-		GetAssociatedRefNames([]conn.AssociatedBranchNamesStub{
-			{Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", Filename: "issue1"},
-			{Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a", Filename: "main_issue1"},
-		}, nil, nil).
+		GetRefs("main_issue1", nil, nil).
+		GetCommitGraph("issue1", nil, nil).
 		GetPullRequests("issue1Merged", nil, nil).
 		GetUncommittedChanges([]conn.UncommittedChangeStub{
 			{Path: "", Output: ""},
 			{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_basic", Output: ""},
 		}, nil, nil).
 		GetWorktrees("none", nil, nil).
+		GetOperations([]conn.OperationStub{
+			{Path: "", Filename: "none"},
+		}, nil, nil).
 		CheckoutBranch(ErrCommand, nil).
 		GetConfig([]conn.ConfigStub{
 			{Key: "remote.origin.gh-resolved", Filename: "empty"},
@@ -1613,7 +2817,7 @@ func Test_ReturnsErrorWhenCheckoutBranchFails(t *testing.T) {
 		}, nil, nil)
 	remotes, _ := GetPreferredRemotes(context.Background(), s.Conn, shared.Deep)
 
-	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false)
+	_, err := GetBranches(context.Background(), remotes, s.Conn, shared.Merged, shared.Deep, false, Options{})
 
 	assert.NotNil(t, err)
 }
@@ -1625,6 +2829,9 @@ func Test_DeleteBranches(t *testing.T) {
 
 		s := conn.Setup(ctrl).
 			GetBranchNames("@main", nil, nil).
+			GetStashes("empty", nil, nil).
+			GetReverts("empty", nil, nil).
+			GetBranchProtections("empty", nil, nil).
 			DeleteBranches(nil, conn.NewConf(&conn.Times{N: 1}))
 
 		branches := []shared.Branch{
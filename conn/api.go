@@ -0,0 +1,543 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/seachicken/gh-poi/shared"
+)
+
+var (
+	ErrUnauthorized = errors.New("GitHub API authentication failed, run `gh auth login` to authenticate")
+	ErrRateLimited  = errors.New("GitHub API rate limit exceeded")
+)
+
+const (
+	maxRetries    = 3
+	maxRetryDelay = time.Minute
+)
+
+var retryBaseDelay = time.Second
+
+type (
+	RateLimit struct {
+		Limit     int
+		Remaining int
+		Reset     time.Time
+	}
+
+	rateLimitTransport struct {
+		conn     *Connection
+		hostname string
+		base     http.RoundTripper
+	}
+
+	repositoryResponse struct {
+		Name  string
+		Owner struct {
+			Login string
+		}
+		Parent *struct {
+			Name  string
+			Owner struct {
+				Login string
+			}
+			DefaultBranchRef struct {
+				Name string
+			}
+		}
+		DefaultBranchRef struct {
+			Name string
+		}
+	}
+
+	branchProtectionResponse struct {
+		BranchProtectionRules struct {
+			Nodes []struct {
+				Pattern string
+			}
+		}
+		Rulesets struct {
+			Nodes []struct {
+				Target      string
+				Enforcement string
+				Conditions  struct {
+					RefName *struct {
+						Include []string
+						Exclude []string
+					}
+				}
+				Rules struct {
+					Nodes []struct {
+						Type string
+					}
+				}
+			}
+		}
+	}
+
+	searchResponse struct {
+		Search struct {
+			IssueCount int
+			Edges      []struct {
+				Node pullRequestNode
+			}
+		}
+	}
+
+	pullRequestNode struct {
+		Number      int
+		HeadRefName string
+		BaseRefName string
+		Url         string
+		State       string
+		IsDraft     bool
+		Commits     struct {
+			Nodes []struct {
+				Commit struct {
+					Oid  string
+					Tree struct {
+						Oid string
+					}
+				}
+			}
+		}
+		Author struct {
+			Login string
+		}
+		Labels struct {
+			Nodes []struct {
+				Name string
+			}
+		}
+		// Null when the head repository has been deleted
+		HeadRepository *struct {
+			NameWithOwner string
+		}
+		BaseRepository *struct {
+			NameWithOwner string
+		}
+		// Null unless the PR is merged
+		MergeCommit *struct {
+			Oid string
+		}
+	}
+)
+
+const repositoryQuery = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    name
+    owner { login }
+    parent {
+      name
+      owner { login }
+      defaultBranchRef { name }
+    }
+    defaultBranchRef { name }
+  }
+}`
+
+const branchProtectionsQuery = `query($owner: String!, $name: String!) {
+  repository(owner: $owner, name: $name) {
+    branchProtectionRules(first: 100) {
+      nodes { pattern }
+    }
+    rulesets(first: 100, includeParents: true) {
+      nodes {
+        target
+        enforcement
+        conditions {
+          refName { include exclude }
+        }
+        rules(first: 100) {
+          nodes { type }
+        }
+      }
+    }
+  }
+}`
+
+// Rules that mark a branch as long-lived, unlike rules such as requiring signed commits that apply to any branch
+var protectingRuleTypes = []string{"DELETION", "UPDATE", "PULL_REQUEST"}
+
+// limitations:
+// - https://docs.github.com/en/search-github/searching-on-github/searching-issues-and-pull-requests#search-within-a-users-or-organizations-repositories
+// - https://docs.github.com/en/graphql/overview/resource-limitations
+const pullRequestsQuery = `query($q: String!) {
+  search(type: ISSUE, query: $q, last: 100) {
+    issueCount
+    edges {
+      node {
+        ... on PullRequest {
+          number
+          url
+          state
+          isDraft
+          headRefName
+          baseRefName
+          headRepository { nameWithOwner }
+          baseRepository { nameWithOwner }
+          commits(last: 100) {
+            nodes {
+              commit {
+                oid
+                tree { oid }
+              }
+            }
+          }
+          author { login }
+          labels(first: 100) {
+            nodes { name }
+          }
+          mergeCommit { oid }
+        }
+      }
+    }
+  }
+}`
+
+func (conn *Connection) GetRepoNames(ctx context.Context, hostname string, repoName string) (shared.Repository, error) {
+	owner, name, _ := strings.Cut(repoName, "/")
+	var resp struct {
+		Repository repositoryResponse
+	}
+	variables := map[string]interface{}{
+		"owner": owner,
+		"name":  name,
+	}
+	if err := conn.query(ctx, hostname, repositoryQuery, variables, &resp); err != nil {
+		return shared.Repository{}, err
+	}
+	return toRepository(resp.Repository), nil
+}
+
+func (conn *Connection) GetBranchProtections(ctx context.Context, hostname string, repoName string) ([]shared.BranchProtection, error) {
+	owner, name, _ := strings.Cut(repoName, "/")
+	var resp struct {
+		Repository branchProtectionResponse
+	}
+	variables := map[string]interface{}{
+		"owner": owner,
+		"name":  name,
+	}
+	if err := conn.query(ctx, hostname, branchProtectionsQuery, variables, &resp); err != nil {
+		return nil, err
+	}
+	return toBranchProtections(resp.Repository), nil
+}
+
+func (conn *Connection) GetPullRequests(
+	ctx context.Context,
+	hostname string, orgs string, repos string, queryHashes string) ([]shared.PullRequest, error) {
+	var resp searchResponse
+	variables := map[string]interface{}{
+		"q": fmt.Sprintf("is:pr %s %s %s", orgs, repos, queryHashes),
+	}
+	if err := conn.query(ctx, hostname, pullRequestsQuery, variables, &resp); err != nil {
+		return nil, err
+	}
+	return toPullRequests(resp)
+}
+
+func (conn *Connection) query(ctx context.Context, hostname string, query string, variables map[string]interface{}, response interface{}) error {
+	transport := conn.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client, err := api.NewGraphQLClient(api.ClientOptions{
+		Host:         hostname,
+		Transport:    &rateLimitTransport{conn: conn, hostname: hostname, base: transport},
+		LogIgnoreEnv: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Another connection sharing the budget may have already used up the quota
+	if limit := conn.rateLimit(hostname); limit.Limit > 0 && limit.Remaining == 0 && time.Now().Before(limit.Reset) {
+		delay, ok := capRetryDelay(time.Until(limit.Reset))
+		if !ok {
+			return fmt.Errorf("%w (%s, %s)", ErrRateLimited, hostname, limit)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		err = client.DoWithContext(ctx, query, variables, response)
+		duration := time.Since(start).Milliseconds()
+		if err == nil {
+			if conn.Debug {
+				log.Printf("[%7.0dms] api graphql --hostname %s %v -> %+v (%s)\n",
+					duration, hostname, variables, response, conn.rateLimit(hostname))
+			}
+			return nil
+		}
+
+		delay, ok := conn.retryDelay(hostname, err, attempt)
+		if !ok || attempt >= maxRetries {
+			return conn.toAPIError(hostname, err)
+		}
+		if conn.Debug {
+			log.Printf("[%7.0dms] api graphql --hostname %s %v -> retrying in %v: %v\n",
+				duration, hostname, variables, delay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Returns how long to wait before retrying a failed request and whether it should be retried at all.
+// Secondary rate limits and server errors are retried with exponential backoff,
+// preferring the wait time the server asks for via `Retry-After` or `X-RateLimit-Reset`.
+//
+// ref. https://docs.github.com/en/rest/using-the-rest-api/best-practices-for-using-the-rest-api#handle-rate-limit-errors-appropriately
+func (conn *Connection) retryDelay(hostname string, err error, attempt int) (time.Duration, bool) {
+	backoff := retryBaseDelay * time.Duration(1<<attempt)
+
+	var httpErr *api.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode >= http.StatusInternalServerError {
+			return backoff, true
+		}
+		if httpErr.StatusCode != http.StatusForbidden && httpErr.StatusCode != http.StatusTooManyRequests {
+			return 0, false
+		}
+		if retryAfter, err := strconv.Atoi(httpErr.Headers.Get("Retry-After")); err == nil {
+			return capRetryDelay(time.Duration(retryAfter) * time.Second)
+		}
+		if limit := parseRateLimit(httpErr.Headers); httpErr.Headers.Get("X-RateLimit-Remaining") == "0" && !limit.Reset.IsZero() {
+			return capRetryDelay(time.Until(limit.Reset))
+		}
+		if isRateLimited(httpErr) {
+			return backoff, true
+		}
+		return 0, false
+	}
+
+	var gqlErr *api.GraphQLError
+	if errors.As(err, &gqlErr) && isGraphQLRateLimited(gqlErr) {
+		if limit := conn.rateLimit(hostname); limit.Remaining == 0 && !limit.Reset.IsZero() {
+			return capRetryDelay(time.Until(limit.Reset))
+		}
+		return backoff, true
+	}
+
+	return 0, false
+}
+
+func capRetryDelay(delay time.Duration) (time.Duration, bool) {
+	if delay > maxRetryDelay {
+		return 0, false
+	}
+	return max(delay, 0), true
+}
+
+func (conn *Connection) toAPIError(hostname string, err error) error {
+	var httpErr *api.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.StatusCode == http.StatusUnauthorized {
+			return fmt.Errorf("%w (%s): %w", ErrUnauthorized, hostname, err)
+		}
+		if isRateLimited(httpErr) {
+			return fmt.Errorf("%w (%s, %s): %w", ErrRateLimited, hostname, conn.rateLimit(hostname), err)
+		}
+	}
+
+	var gqlErr *api.GraphQLError
+	if errors.As(err, &gqlErr) && isGraphQLRateLimited(gqlErr) {
+		return fmt.Errorf("%w (%s, %s): %w", ErrRateLimited, hostname, conn.rateLimit(hostname), err)
+	}
+
+	return fmt.Errorf("failed to call GitHub API: %s\n %w", hostname, err)
+}
+
+func isRateLimited(err *api.HTTPError) bool {
+	return err.StatusCode == http.StatusTooManyRequests ||
+		(err.StatusCode == http.StatusForbidden &&
+			(err.Headers.Get("X-RateLimit-Remaining") == "0" ||
+				err.Headers.Get("Retry-After") != "" ||
+				strings.Contains(strings.ToLower(err.Message), "rate limit")))
+}
+
+func isGraphQLRateLimited(err *api.GraphQLError) bool {
+	for _, e := range err.Errors {
+		if e.Type == "RATE_LIMITED" {
+			return true
+		}
+	}
+	return false
+}
+
+func (conn *Connection) rateLimit(hostname string) RateLimit {
+	root := conn.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	return root.rateLimits[hostname]
+}
+
+func (conn *Connection) setRateLimit(hostname string, limit RateLimit) {
+	root := conn.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.rateLimits == nil {
+		root.rateLimits = make(map[string]RateLimit)
+	}
+	root.rateLimits[hostname] = limit
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.Header.Get("X-RateLimit-Limit") != "" {
+		t.conn.setRateLimit(t.hostname, parseRateLimit(resp.Header))
+	}
+	return resp, err
+}
+
+func parseRateLimit(header http.Header) RateLimit {
+	limit, _ := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	limitReset := RateLimit{Limit: limit, Remaining: remaining}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		limitReset.Reset = time.Unix(reset, 0)
+	}
+	return limitReset
+}
+
+func (r RateLimit) String() string {
+	if r.Limit == 0 {
+		return "remaining quota unknown"
+	}
+	return fmt.Sprintf("%d/%d requests remaining, resets at %s",
+		r.Remaining, r.Limit, r.Reset.Local().Format(time.TimeOnly))
+}
+
+func toRepository(resp repositoryResponse) shared.Repository {
+	repo := shared.Repository{
+		Owner:             resp.Owner.Login,
+		Name:              resp.Name,
+		DefaultBranchName: resp.DefaultBranchRef.Name,
+	}
+	if resp.Parent != nil && len(resp.Parent.Name) > 0 {
+		repo.Parent = &shared.Repository{
+			Owner:             resp.Parent.Owner.Login,
+			Name:              resp.Parent.Name,
+			DefaultBranchName: resp.Parent.DefaultBranchRef.Name,
+		}
+	}
+	return repo
+}
+
+func toPullRequests(resp searchResponse) ([]shared.PullRequest, error) {
+	results := []shared.PullRequest{}
+	for _, edge := range resp.Search.Edges {
+		state, err := toPullRequestState(edge.Node.State)
+		if err != nil {
+			return nil, err
+		}
+
+		commits := []string{}
+		headTreeOid := ""
+		for _, node := range edge.Node.Commits.Nodes {
+			commits = append(commits, node.Commit.Oid)
+			headTreeOid = node.Commit.Tree.Oid
+		}
+
+		headRepoName := ""
+		if edge.Node.HeadRepository != nil {
+			headRepoName = edge.Node.HeadRepository.NameWithOwner
+		}
+		baseRepoName := ""
+		if edge.Node.BaseRepository != nil {
+			baseRepoName = edge.Node.BaseRepository.NameWithOwner
+		}
+
+		labels := []string{}
+		for _, node := range edge.Node.Labels.Nodes {
+			labels = append(labels, node.Name)
+		}
+
+		mergeCommitOid := ""
+		if edge.Node.MergeCommit != nil {
+			mergeCommitOid = edge.Node.MergeCommit.Oid
+		}
+
+		results = append(results, shared.PullRequest{
+			Name:           edge.Node.HeadRefName,
+			State:          state,
+			IsDraft:        edge.Node.IsDraft,
+			Number:         edge.Node.Number,
+			Commits:        commits,
+			Url:            edge.Node.Url,
+			Author:         edge.Node.Author.Login,
+			HeadRepoName:   headRepoName,
+			HeadTreeOid:    headTreeOid,
+			MergeCommitOid: mergeCommitOid,
+			BaseRefName:    edge.Node.BaseRefName,
+			BaseRepoName:   baseRepoName,
+			Labels:         labels,
+		})
+	}
+	return results, nil
+}
+
+func toBranchProtections(resp branchProtectionResponse) []shared.BranchProtection {
+	results := []shared.BranchProtection{}
+	for _, rule := range resp.BranchProtectionRules.Nodes {
+		results = append(results, shared.BranchProtection{Include: []string{rule.Pattern}, Exclude: []string{}})
+	}
+	for _, ruleset := range resp.Rulesets.Nodes {
+		if ruleset.Target != "BRANCH" || ruleset.Enforcement != "ACTIVE" || ruleset.Conditions.RefName == nil {
+			continue
+		}
+		isProtecting := false
+		for _, rule := range ruleset.Rules.Nodes {
+			isProtecting = isProtecting || slices.Contains(protectingRuleTypes, rule.Type)
+		}
+		if !isProtecting {
+			continue
+		}
+		results = append(results, shared.BranchProtection{
+			Include: trimRefPrefixes(ruleset.Conditions.RefName.Include),
+			Exclude: trimRefPrefixes(ruleset.Conditions.RefName.Exclude),
+		})
+	}
+	return results
+}
+
+func trimRefPrefixes(patterns []string) []string {
+	results := []string{}
+	for _, pattern := range patterns {
+		results = append(results, strings.TrimPrefix(pattern, "refs/heads/"))
+	}
+	return results
+}
+
+func toPullRequestState(state string) (shared.PullRequestState, error) {
+	switch state {
+	case "CLOSED":
+		return shared.Closed, nil
+	case "MERGED":
+		return shared.Merged, nil
+	case "OPEN":
+		return shared.Open, nil
+	default:
+		return 0, fmt.Errorf("unexpected pull request state: %s", state)
+	}
+}
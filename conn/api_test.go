@@ -0,0 +1,332 @@
+package conn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/seachicken/gh-poi/shared"
+	"github.com/stretchr/testify/assert"
+)
+
+type redirectTransport struct {
+	url *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.url.Scheme
+	req.URL.Host = t.url.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func setupGraphQLServer(t *testing.T, handler http.HandlerFunc) *Connection {
+	t.Setenv("GH_TOKEN", "token")
+	retryBaseDelayOrg := retryBaseDelay
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = retryBaseDelayOrg })
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, _ := url.Parse(server.URL)
+	return &Connection{Transport: redirectTransport{u}}
+}
+
+func readGraphQLRequest(t *testing.T, r *http.Request) map[string]interface{} {
+	var body struct {
+		Query     string
+		Variables map[string]interface{}
+	}
+	b, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatalf("%v", err)
+	}
+	return body.Variables
+}
+
+func Test_GetRepoNames(t *testing.T) {
+	stub := &Stub{nil, t}
+
+	t.Run("returns repository with parent", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			variables := readGraphQLRequest(t, r)
+			assert.Equal(t, "owner", variables["owner"])
+			assert.Equal(t, "repo", variables["name"])
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"data":{"repository":%s}}`, stub.ReadFile("gh", "repo", "origin_upstream"))
+		})
+
+		actual, err := conn.GetRepoNames(context.Background(), "github.com", "owner/repo")
+
+		assert.Nil(t, err)
+		assert.Equal(t,
+			shared.Repository{
+				Owner:             "owner",
+				Name:              "repo",
+				DefaultBranchName: "main",
+				Parent:            &shared.Repository{Owner: "parent-owner", Name: "repo", DefaultBranchName: "main"},
+			},
+			actual,
+		)
+	})
+
+	t.Run("returns repository without parent", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"data":{"repository":%s}}`, stub.ReadFile("gh", "repo", "origin"))
+		})
+
+		actual, err := conn.GetRepoNames(context.Background(), "github.com", "owner/repo")
+
+		assert.Nil(t, err)
+		assert.Nil(t, actual.Parent)
+		assert.Equal(t, "owner/repo", actual.NameWithOwner())
+	})
+}
+
+func Test_GetBranchProtections(t *testing.T) {
+	stub := &Stub{nil, t}
+
+	t.Run("returns rules and active rulesets that keep branches", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			variables := readGraphQLRequest(t, r)
+			assert.Equal(t, "owner", variables["owner"])
+			assert.Equal(t, "repo", variables["name"])
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"data":{"repository":%s}}`, stub.ReadFile("gh", "protection", "issue1"))
+		})
+
+		actual, err := conn.GetBranchProtections(context.Background(), "github.com", "owner/repo")
+
+		assert.Nil(t, err)
+		assert.Equal(t,
+			[]shared.BranchProtection{
+				{Include: []string{"release/*"}, Exclude: []string{}},
+				{Include: []string{"issue*"}, Exclude: []string{}},
+			},
+			actual,
+		)
+	})
+}
+
+func Test_GetPullRequests(t *testing.T) {
+	stub := &Stub{nil, t}
+
+	t.Run("returns pull requests", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			variables := readGraphQLRequest(t, r)
+			assert.Equal(t,
+				"is:pr org:owner repo:owner/repo hash:a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0",
+				variables["q"],
+			)
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, stub.ReadFile("gh", "pr", "issue1Merged"))
+		})
+
+		actual, err := conn.GetPullRequests(context.Background(), "github.com",
+			"org:owner", "repo:owner/repo", "hash:a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0")
+
+		assert.Nil(t, err)
+		assert.Equal(t,
+			[]shared.PullRequest{
+				{
+					Name:         "issue1",
+					State:        shared.Merged,
+					Number:       1,
+					Commits:      []string{"a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0"},
+					Url:          "https://github.com/owner/repo/pull/1",
+					Author:       "owner",
+					HeadRepoName: "owner/repo",
+					Labels:       []string{},
+				},
+			},
+			actual,
+		)
+	})
+
+	t.Run("returns base repository of pull requests", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, stub.ReadFile("gh", "pr", "issue1UpMerged"))
+		})
+
+		actual, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, "main", actual[0].BaseRefName)
+		assert.Equal(t, "parent-owner/repo", actual[0].BaseRepoName)
+	})
+
+	t.Run("returns unauthorized error", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"message":"Bad credentials"}`)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("returns rate limited error", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"API rate limit exceeded"}`)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("returns rate limited error from graphql errors", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrRateLimited)
+	})
+
+	t.Run("returns error for unexpected state", func(t *testing.T) {
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, strings.ReplaceAll(stub.ReadFile("gh", "pr", "issue1Merged"), "MERGED", "UNKNOWN"))
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.NotNil(t, err)
+	})
+}
+
+func Test_GetPullRequestsWithRetry(t *testing.T) {
+	stub := &Stub{nil, t}
+
+	t.Run("retries on server errors", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			if calls < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			io.WriteString(w, stub.ReadFile("gh", "pr", "issue1Merged"))
+		})
+
+		actual, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, 1, len(actual))
+	})
+
+	t.Run("retries after secondary rate limit", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			if calls == 1 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusForbidden)
+				io.WriteString(w, `{"message":"You have exceeded a secondary rate limit."}`)
+				return
+			}
+			io.WriteString(w, stub.ReadFile("gh", "pr", "issue1Merged"))
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("gives up with remaining quota when rate limit does not reset soon", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"API rate limit exceeded"}`)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.ErrorContains(t, err, "0/5000 requests remaining")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.NotNil(t, err)
+		assert.Equal(t, maxRetries+1, calls)
+	})
+
+	t.Run("does not call API when quota shared with another connection is exhausted", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-RateLimit-Limit", "5000")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			io.WriteString(w, stub.ReadFile("gh", "pr", "issue1Merged"))
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+		assert.Nil(t, err)
+		_, err = conn.WithDir("other").GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("returns client errors as they are", func(t *testing.T) {
+		t.Setenv("GH_TOKEN", "")
+		t.Setenv("GH_ENTERPRISE_TOKEN", "")
+		t.Setenv("GH_CONFIG_DIR", t.TempDir())
+		conn := &Connection{}
+
+		_, err := conn.GetPullRequests(context.Background(), "example.com", "", "", "")
+
+		assert.NotNil(t, err)
+		assert.NotErrorIs(t, err, ErrUnauthorized)
+	})
+
+	t.Run("does not retry on unauthorized", func(t *testing.T) {
+		calls := 0
+		conn := setupGraphQLServer(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		_, err := conn.GetPullRequests(context.Background(), "github.com", "", "", "")
+
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		assert.Equal(t, 1, calls)
+	})
+}
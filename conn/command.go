@@ -3,13 +3,20 @@ package conn
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cli/safeexec"
@@ -19,9 +26,25 @@ import (
 type (
 	Connection struct {
 		Debug bool
+		// Dir makes git commands run as if git was started in this directory instead of the current one.
+		Dir string
+		// Transport overrides the HTTP transport used for GitHub API requests.
+		Transport http.RoundTripper
+
+		mu         sync.Mutex
+		rateLimits map[string]RateLimit
+		// Connections created by WithDir record rate limits to the connection they were created from.
+		parent *Connection
 	}
 
 	DebugMask int
+
+	// A remote that cannot be used to find pull requests.
+	SkippedRemote struct {
+		Name   string
+		Url    string
+		Reason string
+	}
 )
 
 const (
@@ -30,16 +53,42 @@ const (
 )
 
 var (
-	hasSchemePattern  = regexp.MustCompile("^[^:]+://")
-	scpLikeURLPattern = regexp.MustCompile("^([^@]+@)?([^:]+):(/?.+)$")
+	hasSchemePattern       = regexp.MustCompile("^[^:]+://")
+	scpLikeURLPattern      = regexp.MustCompile("^([^@]+@)?([^:]+):(/?.+)$")
+	submoduleStatusPattern = regexp.MustCompile(`^([ +\-U])[0-9a-f]+ (.+?)(?: \(.*\))?$`)
+	stashSubjectPattern    = regexp.MustCompile(`^(?:WIP on|On) ([^:]+):`)
+	revertSubjectPattern   = regexp.MustCompile(`^((?:(?:Revert|Reapply) ")+)(.*)"`)
+	prNumberPattern        = regexp.MustCompile(`\(#(\d+)\)`)
+	revertedCommitPattern  = regexp.MustCompile(`^This reverts commit ([0-9a-f]{40})`)
+	revertedPRPattern      = regexp.MustCompile(`^Reverts [^\s#]+#(\d+)`)
 )
 
-func GetRemoteNames(ctx context.Context, conn shared.Connection) ([]shared.Remote, error) {
+// Returns a connection that runs git commands in the directory,
+// sharing the GitHub API rate limit budget with this connection.
+func (conn *Connection) WithDir(dir string) *Connection {
+	return &Connection{
+		Debug:     conn.Debug,
+		Dir:       dir,
+		Transport: conn.Transport,
+		parent:    conn.root(),
+	}
+}
+
+func (conn *Connection) root() *Connection {
+	if conn.parent != nil {
+		return conn.parent
+	}
+	return conn
+}
+
+// Returns the remotes hosted on a git server, along with the remotes skipped because their URL is not supported.
+func GetRemoteNames(ctx context.Context, conn shared.Connection) ([]shared.Remote, []SkippedRemote, error) {
 	output, err := conn.GetRemoteNames(ctx)
 	if err != nil {
-		return []shared.Remote{}, err
+		return []shared.Remote{}, []SkippedRemote{}, err
 	}
-	return parseRemotes(output), nil
+	remotes, skipped := parseRemotes(output)
+	return remotes, skipped, nil
 }
 
 func (conn *Connection) GetRemoteNames(ctx context.Context) (string, error) {
@@ -62,58 +111,102 @@ func (conn *Connection) GetRemoteNames(ctx context.Context) (string, error) {
 //
 // ref. http://git-scm.com/docs/git-fetch#_git_urls
 // the code is heavily inspired by https://github.com/x-motemen/ghq/blob/7163e61e2309a039241ad40b4a25bea35671ea6f/url.go
-func parseRemotes(output string) []shared.Remote {
+//
+// Fetch URLs are preferred over push URLs. git prints the URLs with insteadOf and pushInsteadOf rules already applied.
+// Remotes that are not hosted on a git server, such as local paths, are skipped individually.
+func parseRemotes(output string) ([]shared.Remote, []SkippedRemote) {
 	results := []shared.Remote{}
+	skipped := []SkippedRemote{}
 
+	type remoteURL struct {
+		name string
+		url  string
+		push bool
+	}
+	urls := []remoteURL{}
 	for _, remoteConfig := range splitLines(output) {
+		// e.g. "origin	https://github.com/org/repo.git (fetch) [blob:none]" for partial clones
 		splitConfig := strings.Fields(remoteConfig)
-		if len(splitConfig) != 3 {
-			return []shared.Remote{}
+		if len(splitConfig) < 3 {
+			if len(splitConfig) > 0 {
+				skipped = append(skipped, SkippedRemote{Name: splitConfig[0], Reason: "unexpected format: " + remoteConfig})
+			}
+			continue
 		}
 
-		ref := splitConfig[1]
-		if !hasSchemePattern.MatchString(ref) {
-			if scpLikeURLPattern.MatchString(ref) {
-				matched := scpLikeURLPattern.FindStringSubmatch(ref)
-				user := matched[1]
-				host := matched[2]
-				path := matched[3]
-				ref = fmt.Sprintf("ssh://%s%s/%s", user, host, strings.TrimPrefix(path, "/"))
-			}
+		name := splitConfig[0]
+		push := splitConfig[2] == "(push)"
+		i := slices.IndexFunc(urls, func(u remoteURL) bool { return u.name == name })
+		if i < 0 {
+			urls = append(urls, remoteURL{name, splitConfig[1], push})
+		} else if urls[i].push && !push {
+			urls[i] = remoteURL{name, splitConfig[1], push}
 		}
-		u, err := url.Parse(ref)
+	}
+
+	for _, remoteURL := range urls {
+		remote, err := parseRemoteURL(remoteURL.name, remoteURL.url)
 		if err != nil {
-			return []shared.Remote{}
+			skipped = append(skipped, SkippedRemote{Name: remoteURL.name, Url: remoteURL.url, Reason: err.Error()})
+			continue
 		}
+		results = append(results, remote)
+	}
 
-		repo := u.Path
-		repo = strings.TrimPrefix(repo, "/")
-		repo = strings.TrimSuffix(repo, "/")
-		repo = strings.TrimSuffix(repo, ".git")
+	return results, skipped
+}
 
-		results = append(results, shared.Remote{
-			Name:     splitConfig[0],
-			Hostname: u.Host,
-			RepoName: repo,
-		})
+func parseRemoteURL(name string, ref string) (shared.Remote, error) {
+	if !hasSchemePattern.MatchString(ref) {
+		if scpLikeURLPattern.MatchString(ref) {
+			matched := scpLikeURLPattern.FindStringSubmatch(ref)
+			user := matched[1]
+			host := matched[2]
+			path := matched[3]
+			ref = fmt.Sprintf("ssh://%s%s/%s", user, host, strings.TrimPrefix(path, "/"))
+		}
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return shared.Remote{}, err
+	}
+	if u.Scheme == "file" || u.Host == "" {
+		return shared.Remote{}, errors.New("not hosted on a git server")
 	}
 
-	return results
+	repo := u.Path
+	repo = strings.TrimPrefix(repo, "/")
+	repo = strings.TrimSuffix(repo, "/")
+	repo = strings.TrimSuffix(repo, ".git")
+	if !strings.Contains(repo, "/") {
+		return shared.Remote{}, fmt.Errorf("repository name is not in the form of owner/repo: %s", repo)
+	}
+
+	return shared.Remote{
+		Name:     name,
+		Hostname: u.Host,
+		RepoName: repo,
+	}, nil
 }
 
+// Returns the resolved ssh configuration in the format of `ssh -G`.
+// The hostname is resolved from ssh_config files in-process when possible,
+// since ssh may not be installed and `Match exec` blocks make `ssh -G` slow.
 func (conn *Connection) GetSshConfig(ctx context.Context, name string) (string, error) {
-	args := []string{
-		"-T", "-G", name,
+	start := time.Now()
+	if hostname, err := resolveSshHostname(name, defaultSshConfigFiles()); err == nil {
+		if conn.Debug {
+			log.Printf("[%7.0dms] resolve ssh_config %s -> hostname %s\n", time.Since(start).Milliseconds(), name, hostname)
+		}
+		return fmt.Sprintf("hostname %s\n", hostname), nil
+	} else if conn.Debug {
+		log.Printf("[%7.0dms] resolve ssh_config %s -> falling back to ssh: %v\n", time.Since(start).Milliseconds(), name, err)
 	}
-	return conn.run(ctx, "ssh", args, Output)
-}
 
-func (conn *Connection) GetRepoNames(ctx context.Context, hostname string, repoName string) (string, error) {
 	args := []string{
-		"repo", "view", hostname + "/" + repoName,
-		"--json", "owner,name,parent,defaultBranchRef",
+		"-T", "-G", name,
 	}
-	return conn.run(ctx, "gh", args, None)
+	return conn.run(ctx, "ssh", args, Output)
 }
 
 func (conn *Connection) GetBranchNames(ctx context.Context) (string, error) {
@@ -145,58 +238,133 @@ func (conn *Connection) GetUpstreamOid(ctx context.Context, branchName string) (
 	return conn.run(ctx, "git", args, None)
 }
 
-func (conn *Connection) GetLog(ctx context.Context, branchName string) (string, error) {
+func (conn *Connection) GetLog(ctx context.Context, branchName string, maxCount int) (string, error) {
 	args := []string{
-		"log", "--max-count=30", "--format=%H", branchName, "--",
+		"log", fmt.Sprintf("--max-count=%d", maxCount), "--format=%H", branchName, "--",
 	}
 	return conn.run(ctx, "git", args, None)
 }
 
-func (conn *Connection) GetAssociatedRefNames(ctx context.Context, oid string) (string, error) {
+// Loads the containment of refs for the commits that are not reachable from the boundary branch,
+// so that ancestry checks don't need to run `git branch --contains` for each commit.
+func GetCommitGraph(ctx context.Context, conn shared.Connection, boundaryBranchName string) (*shared.CommitGraph, error) {
+	refsOutput, err := conn.GetRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	refs := parseRefs(refsOutput)
+
+	boundary := []shared.Ref{}
+	boundaryNames := []string{}
+	for _, ref := range refs {
+		if ref.BranchName() == boundaryBranchName {
+			boundary = append(boundary, ref)
+			boundaryNames = append(boundaryNames, ref.Name)
+		}
+	}
+
+	graphOutput, err := conn.GetCommitGraph(ctx, boundaryNames)
+	if err != nil {
+		return nil, err
+	}
+	return shared.NewCommitGraph(refs, boundary, parseCommitGraph(graphOutput)), nil
+}
+
+func (conn *Connection) GetRefs(ctx context.Context) (string, error) {
 	args := []string{
-		"branch", "--all", "--format=%(refname)",
-		"--contains", oid,
+		"for-each-ref", "--format=%(refname) %(objectname)",
+		"refs/heads", "refs/remotes",
 	}
 	return conn.run(ctx, "git", args, None)
 }
 
-// limitations:
-// - https://docs.github.com/en/search-github/searching-on-github/searching-issues-and-pull-requests#search-within-a-users-or-organizations-repositories
-// - https://docs.github.com/en/graphql/overview/resource-limitations
-func (conn *Connection) GetPullRequests(
-	ctx context.Context,
-	hostname string, orgs string, repos string, queryHashes string) (string, error) {
+func (conn *Connection) GetCommitGraph(ctx context.Context, excludedRefNames []string) (string, error) {
+	args := append([]string{
+		"rev-list", "--topo-order", "--parents",
+		"--branches", "--remotes", "--not"},
+		excludedRefNames...,
+	)
+	return conn.run(ctx, "git", args, None)
+}
+
+func (conn *Connection) GetTree(ctx context.Context, branchName string) (string, error) {
 	args := []string{
-		"api", "graphql",
-		"--hostname", hostname,
-		"-f", fmt.Sprintf(`query=query {
-  search(type: ISSUE, query: "is:pr %s %s %s", last: 100) {
-    issueCount
-    edges {
-      node {
-        ... on PullRequest {
-          number
-          url
-          state
-          isDraft
-          headRefName
-          commits(last: 100) {
-            nodes {
-              commit {
-                oid
-              }
-            }
-          }
-          author { login }
-        }
-      }
-    }
-  }
-}`,
-			orgs, repos, queryHashes,
-		),
-	}
-	return conn.run(ctx, "gh", args, None)
+		"rev-parse", "--verify", "--quiet", branchName + "^{tree}",
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
+// Returns the patch-ids of the commits on the branch that are not reachable from the excluded ref.
+func GetPatchIds(ctx context.Context, conn shared.Connection, branchName string, excludedRefName string) ([]string, error) {
+	output, err := conn.GetPatchIds(ctx, branchName, excludedRefName)
+	if err != nil {
+		return []string{}, err
+	}
+	return parsePatchIds(output), nil
+}
+
+// Returns the patch-ids of the commits. Commits that do not exist locally are ignored.
+func GetCommitPatchIds(ctx context.Context, conn shared.Connection, oids []string) ([]string, error) {
+	output, err := conn.GetCommitPatchIds(ctx, oids)
+	if err != nil {
+		return []string{}, err
+	}
+	return parsePatchIds(output), nil
+}
+
+func (conn *Connection) GetPatchIds(ctx context.Context, branchName string, excludedRefName string) (string, error) {
+	return conn.getPatchIds(ctx, []string{branchName, "--not", excludedRefName})
+}
+
+func (conn *Connection) GetCommitPatchIds(ctx context.Context, oids []string) (string, error) {
+	return conn.getPatchIds(ctx, append([]string{"--no-walk"}, oids...))
+}
+
+func (conn *Connection) getPatchIds(ctx context.Context, revs []string) (string, error) {
+	args := append([]string{
+		"log", "--patch", "--no-merges", "--no-color", "--no-ext-diff", "--ignore-missing", "--format=commit %H"},
+		revs...,
+	)
+	patches, err := conn.run(ctx, "git", args, Output)
+	if err != nil {
+		return "", err
+	}
+	args = []string{
+		"patch-id", "--stable",
+	}
+	return conn.runWithInput(ctx, "git", args, strings.NewReader(patches), None)
+}
+
+// Parses lines of `<patch-id> <commit-oid>`.
+func parsePatchIds(output string) []string {
+	results := []string{}
+	for _, line := range splitLines(output) {
+		patchId, _, _ := strings.Cut(line, " ")
+		results = append(results, patchId)
+	}
+	return results
+}
+
+func parseRefs(output string) []shared.Ref {
+	results := []shared.Ref{}
+	for _, line := range splitLines(output) {
+		name, oid, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		results = append(results, shared.Ref{Name: name, Oid: oid})
+	}
+	return results
+}
+
+func parseCommitGraph(output string) [][]string {
+	results := [][]string{}
+	for _, line := range splitLines(output) {
+		if oids := strings.Fields(line); len(oids) > 0 {
+			results = append(results, oids)
+		}
+	}
+	return results
 }
 
 func GetUncommittedChanges(ctx context.Context, conn shared.Connection, opts ...string) ([]shared.UncommittedChange, error) {
@@ -226,6 +394,211 @@ func parseUncommittedChanges(output string) []shared.UncommittedChange {
 	return results
 }
 
+func GetSubmodules(ctx context.Context, conn shared.Connection, opts ...string) ([]shared.Submodule, error) {
+	output, err := conn.GetSubmodules(ctx, opts...)
+	if err != nil {
+		return []shared.Submodule{}, err
+	}
+	return parseSubmodules(output), nil
+}
+
+func (conn *Connection) GetSubmodules(ctx context.Context, opts ...string) (string, error) {
+	args := append(opts,
+		"submodule", "status", "--recursive",
+	)
+	return conn.run(ctx, "git", args, None)
+}
+
+// Parses lines such as `+562d9a27d69584e73ddacad6e2407b873d8749f8 libs/lib (heads/main)`,
+// where the prefix `-` means that the submodule is not initialized.
+func parseSubmodules(output string) []shared.Submodule {
+	results := []shared.Submodule{}
+	for _, line := range splitLines(output) {
+		found := submoduleStatusPattern.FindStringSubmatch(line)
+		if found == nil {
+			continue
+		}
+		results = append(results, shared.Submodule{
+			Path:          found[2],
+			IsInitialized: found[1] != "-",
+		})
+	}
+	return results
+}
+
+// Returns the commits on the ref that revert other commits, oldest first.
+func GetReverts(ctx context.Context, conn shared.Connection, refName string) ([]shared.Revert, error) {
+	output, err := conn.GetReverts(ctx, refName)
+	if err != nil {
+		return []shared.Revert{}, err
+	}
+	return parseReverts(output), nil
+}
+
+func (conn *Connection) GetReverts(ctx context.Context, refName string) (string, error) {
+	args := []string{
+		"log", "--reverse", "--extended-regexp",
+		"--grep=^Revert", "--grep=^Reapply", "--grep=^This reverts commit",
+		"--format=commit %H%n%w(0,4,4)%B",
+		refName,
+	}
+	return conn.run(ctx, "git", args, Output)
+}
+
+// Parses commit messages indented under `commit <oid>` lines.
+// The subject of a revert of a revert is `Revert "Revert "..."` or, since Git 2.43, `Reapply "..."`.
+func parseReverts(output string) []shared.Revert {
+	results := []shared.Revert{}
+	var current *shared.Revert
+	isSubject := false
+	for _, line := range splitLines(output) {
+		if oid, ok := strings.CutPrefix(line, "commit "); ok {
+			if current != nil {
+				results = append(results, *current)
+			}
+			current = &shared.Revert{Oid: oid, RevertedOids: []string{}, RevertedNumbers: []int{}}
+			isSubject = true
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if isSubject {
+			isSubject = false
+			if found := revertSubjectPattern.FindStringSubmatch(line); found != nil {
+				depth := strings.Count(found[1], `Revert "`) + strings.Count(found[1], `Reapply "`)*2
+				current.IsReapply = depth%2 == 0
+				for _, number := range prNumberPattern.FindAllStringSubmatch(found[2], -1) {
+					if n, err := strconv.Atoi(number[1]); err == nil {
+						current.RevertedNumbers = append(current.RevertedNumbers, n)
+					}
+				}
+			}
+			continue
+		}
+		if found := revertedCommitPattern.FindStringSubmatch(line); found != nil {
+			current.RevertedOids = append(current.RevertedOids, found[1])
+		} else if found := revertedPRPattern.FindStringSubmatch(line); found != nil {
+			if n, err := strconv.Atoi(found[1]); err == nil && !slices.Contains(current.RevertedNumbers, n) {
+				current.RevertedNumbers = append(current.RevertedNumbers, n)
+			}
+		}
+	}
+	if current != nil {
+		results = append(results, *current)
+	}
+	return results
+}
+
+// Returns the names of the branches that stash entries were created on.
+func GetStashedBranchNames(ctx context.Context, conn shared.Connection) ([]string, error) {
+	output, err := conn.GetStashes(ctx)
+	if err != nil {
+		return []string{}, err
+	}
+	return parseStashedBranchNames(output), nil
+}
+
+func (conn *Connection) GetStashes(ctx context.Context) (string, error) {
+	args := []string{
+		"stash", "list", "--format=%gs",
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
+// Parses reflog subjects such as `WIP on issue1: a97e963 Add a.txt` or `On issue1: message`.
+// Stashes created on a detached HEAD are recorded as `(no branch)` and are skipped.
+func parseStashedBranchNames(output string) []string {
+	results := []string{}
+	for _, line := range splitLines(output) {
+		found := stashSubjectPattern.FindStringSubmatch(line)
+		if found == nil || found[1] == "(no branch)" || slices.Contains(results, found[1]) {
+			continue
+		}
+		results = append(results, found[1])
+	}
+	return results
+}
+
+// Returns the operations in progress in the worktree. An empty path means the current worktree.
+func GetOperations(ctx context.Context, conn shared.Connection, worktreePath string) ([]shared.Operation, error) {
+	output, err := conn.GetOperations(ctx, worktreePath)
+	if err != nil {
+		return []shared.Operation{}, err
+	}
+	return parseOperations(output), nil
+}
+
+func (conn *Connection) GetOperations(ctx context.Context, worktreePath string) (string, error) {
+	args := []string{}
+	if worktreePath != "" {
+		args = append(args, "-C", worktreePath)
+	}
+	args = append(args,
+		"rev-parse", "--absolute-git-dir",
+	)
+	gitDir, err := conn.run(ctx, "git", args, None)
+	if err != nil {
+		return "", err
+	}
+	return readOperations(strings.TrimSpace(gitDir)), nil
+}
+
+// Reads the state files that git leaves in the git directory of a worktree while an operation is stopped,
+// and returns them as lines such as `rebase refs/heads/issue1`, where the second field is the branch if it is recorded.
+func readOperations(gitDir string) string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
+	}
+	read := func(name string) string {
+		b, _ := os.ReadFile(filepath.Join(gitDir, name))
+		return strings.TrimSpace(string(b))
+	}
+
+	var lines []string
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		if exists(dir) && !exists(filepath.Join(dir, "applying")) {
+			lines = append(lines, strings.TrimSpace("rebase "+read(filepath.Join(dir, "head-name"))))
+			break
+		}
+	}
+	if exists("MERGE_HEAD") {
+		lines = append(lines, "merge")
+	}
+	if exists("CHERRY_PICK_HEAD") {
+		lines = append(lines, "cherry-pick")
+	}
+	if exists("REVERT_HEAD") {
+		lines = append(lines, "revert")
+	}
+	if exists("BISECT_START") {
+		lines = append(lines, strings.TrimSpace("bisect refs/heads/"+read("BISECT_START")))
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func parseOperations(output string) []shared.Operation {
+	results := []shared.Operation{}
+	for _, line := range splitLines(output) {
+		kind, ref, _ := strings.Cut(line, " ")
+		results = append(results, shared.Operation{
+			Kind:       kind,
+			BranchName: strings.TrimPrefix(ref, "refs/heads/"),
+		})
+	}
+	return results
+}
+
 func (conn *Connection) GetConfig(ctx context.Context, key string) (string, error) {
 	args := []string{
 		"config", "--get", key,
@@ -264,6 +637,13 @@ func (conn *Connection) CheckoutBranch(ctx context.Context, branchName string, d
 	return conn.run(ctx, "git", args, None)
 }
 
+func (conn *Connection) CreateBranch(ctx context.Context, branchName string, startPoint string) (string, error) {
+	args := []string{
+		"branch", "--no-track", branchName, startPoint,
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
 func (conn *Connection) DeleteBranches(ctx context.Context, branchNames []string) (string, error) {
 	args := append([]string{
 		"branch", "-D"},
@@ -272,6 +652,28 @@ func (conn *Connection) DeleteBranches(ctx context.Context, branchNames []string
 	return conn.run(ctx, "git", args, None)
 }
 
+// Replays the commits of the branch that are not in the upstream on top of onto.
+func (conn *Connection) RebaseBranch(ctx context.Context, branchName string, upstream string, onto string) (string, error) {
+	args := []string{
+		"rebase", "--quiet", "--onto", onto, upstream, branchName,
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
+func (conn *Connection) AbortRebase(ctx context.Context) (string, error) {
+	args := []string{
+		"rebase", "--abort",
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
+func (conn *Connection) SetUpstream(ctx context.Context, branchName string, upstream string) (string, error) {
+	args := []string{
+		"branch", "--quiet", "--set-upstream-to", upstream, branchName,
+	}
+	return conn.run(ctx, "git", args, None)
+}
+
 func (conn *Connection) PruneRemoteBranches(ctx context.Context, remoteName string) (string, error) {
 	args := []string{
 		"remote", "prune", remoteName,
@@ -317,6 +719,8 @@ func parseWorktrees(output string) []shared.Worktree {
 			}
 		} else if line == "locked" {
 			current.IsLocked = true
+		} else if line == "bare" {
+			current.IsBare = true
 		} else if strings.HasPrefix(line, "prunable ") {
 			current.IsPrunable = true
 		}
@@ -344,17 +748,23 @@ func (conn *Connection) PruneWorktrees(ctx context.Context) (string, error) {
 }
 
 func (conn *Connection) run(ctx context.Context, name string, args []string, mask DebugMask) (string, error) {
+	return conn.runWithInput(ctx, name, args, nil, mask)
+}
+
+func (conn *Connection) runWithInput(ctx context.Context, name string, args []string, input io.Reader, mask DebugMask) (string, error) {
 	cmdPath, err := safeexec.LookPath(name)
 	if err != nil {
 		return "", err
 	}
 
+	if name == "git" && conn.Dir != "" {
+		args = append([]string{"-C", conn.Dir}, args...)
+	}
+
 	var stdout bytes.Buffer
 	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.Stdin = input
 	cmd.Stdout = &stdout
-	if name == "gh" {
-		cmd.Env = append(os.Environ(), "CLICOLOR_FORCE=0")
-	}
 
 	start := time.Now()
 	err = cmd.Run()
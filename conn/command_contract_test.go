@@ -38,7 +38,7 @@ func TestContract_RepoBasic(t *testing.T) {
 	t.Run("GetLog", func(t *testing.T) {
 
 		t.Run("main", func(t *testing.T) {
-			actual, _ := conn.GetLog(context.Background(), "main")
+			actual, _ := conn.GetLog(context.Background(), "main", 30)
 			assert.Equal(t,
 				stub.ReadFile("git", "log", "main"),
 				actual,
@@ -46,7 +46,7 @@ func TestContract_RepoBasic(t *testing.T) {
 		})
 
 		t.Run("issue1", func(t *testing.T) {
-			actual, _ := conn.GetLog(context.Background(), "issue1")
+			actual, _ := conn.GetLog(context.Background(), "issue1", 30)
 			assert.Equal(t,
 				stub.ReadFile("git", "log", "issue1"),
 				actual,
@@ -54,23 +54,20 @@ func TestContract_RepoBasic(t *testing.T) {
 		})
 	})
 
-	t.Run("GetAssociatedRefNames", func(t *testing.T) {
-
-		t.Run("issue1", func(t *testing.T) {
-			actual, _ := conn.GetAssociatedRefNames(context.Background(), "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0")
-			assert.Equal(t,
-				stub.ReadFile("git", "abranch", "issue1"),
-				actual,
-			)
-		})
+	t.Run("GetRefs", func(t *testing.T) {
+		actual, _ := conn.GetRefs(context.Background())
+		assert.Equal(t,
+			stub.ReadFile("git", "refs", "main_issue1"),
+			actual,
+		)
+	})
 
-		t.Run("main_issue1", func(t *testing.T) {
-			actual, _ := conn.GetAssociatedRefNames(context.Background(), "6ebe3d30d23531af56bd23b5a098d3ccae2a534a")
-			assert.Equal(t,
-				stub.ReadFile("git", "abranch", "main_issue1"),
-				actual,
-			)
-		})
+	t.Run("GetCommitGraph", func(t *testing.T) {
+		actual, _ := conn.GetCommitGraph(context.Background(), []string{"refs/heads/main"})
+		assert.Equal(t,
+			stub.ReadFile("git", "graph", "issue1"),
+			actual,
+		)
 	})
 
 	t.Run("GetUncommittedChanges", func(t *testing.T) {
@@ -78,6 +75,22 @@ func TestContract_RepoBasic(t *testing.T) {
 		assert.Equal(t, "A  README.md\n", actual)
 	})
 
+	t.Run("GetStashes", func(t *testing.T) {
+		actual, _ := conn.GetStashes(context.Background())
+		assert.Equal(t,
+			stub.ReadFile("git", "stash", "empty"),
+			actual,
+		)
+	})
+
+	t.Run("GetReverts", func(t *testing.T) {
+		actual, _ := conn.GetReverts(context.Background(), "main")
+		assert.Equal(t,
+			stub.ReadFile("git", "revert", "empty"),
+			actual,
+		)
+	})
+
 	t.Run("GetConfig", func(t *testing.T) {
 		actual, _ := conn.GetConfig(context.Background(), "branch.main.merge")
 		assert.Equal(t,
@@ -1,6 +1,8 @@
 package conn
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/seachicken/gh-poi/shared"
@@ -8,6 +10,8 @@ import (
 )
 
 func Test_CreateRemoteWithScpLikeUrl(t *testing.T) {
+	actual, _ := parseRemotes("origin	git@github.com:org/repo (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -16,11 +20,13 @@ func Test_CreateRemoteWithScpLikeUrl(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	git@github.com:org/repo (fetch)"),
+		actual,
 	)
 }
 
 func Test_CreateRemoteWithScpLikeUrlAndCustomUserinfo(t *testing.T) {
+	actual, _ := parseRemotes("origin	git0-._~@github.com:org/repo (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -29,11 +35,13 @@ func Test_CreateRemoteWithScpLikeUrlAndCustomUserinfo(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	git0-._~@github.com:org/repo (fetch)"),
+		actual,
 	)
 }
 
 func Test_CreateRemoteWithSshUrl(t *testing.T) {
+	actual, _ := parseRemotes("origin	ssh://git@github.com/org/repo.git (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -42,11 +50,13 @@ func Test_CreateRemoteWithSshUrl(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	ssh://git@github.com/org/repo.git (fetch)"),
+		actual,
 	)
 }
 
 func Test_CreateRemoteWithScpLikeUrlWithoutUserinfo(t *testing.T) {
+	actual, _ := parseRemotes("origin	github.com:org/repo.git (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -55,11 +65,13 @@ func Test_CreateRemoteWithScpLikeUrlWithoutUserinfo(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	github.com:org/repo.git (fetch)"),
+		actual,
 	)
 }
 
 func Test_CreateRemoteWithHttps(t *testing.T) {
+	actual, _ := parseRemotes("origin	https://github.com/org/repo.git (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -68,12 +80,14 @@ func Test_CreateRemoteWithHttps(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	https://github.com/org/repo.git (fetch)"),
+		actual,
 	)
 }
 
 // https://github.com/seachicken/gh-poi/issues/152
 func Test_CreateRemoteWithHttpsTrailingSlash(t *testing.T) {
+	actual, _ := parseRemotes("origin	https://github.com/org/repo.git/ (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -82,12 +96,14 @@ func Test_CreateRemoteWithHttpsTrailingSlash(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	https://github.com/org/repo.git/ (fetch)"),
+		actual,
 	)
 }
 
 // https://github.com/seachicken/gh-poi/issues/152
 func Test_CreateRemoteWithHttpsTrailingSlashWithoutDotGit(t *testing.T) {
+	actual, _ := parseRemotes("origin	https://github.com/org/repo/ (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -96,12 +112,14 @@ func Test_CreateRemoteWithHttpsTrailingSlashWithoutDotGit(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	https://github.com/org/repo/ (fetch)"),
+		actual,
 	)
 }
 
 // https://github.com/seachicken/gh-poi/issues/152
 func Test_CreateRemoteWithSshUrlTrailingSlash(t *testing.T) {
+	actual, _ := parseRemotes("origin	ssh://git@github.com/org/repo.git/ (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -110,12 +128,14 @@ func Test_CreateRemoteWithSshUrlTrailingSlash(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	ssh://git@github.com/org/repo.git/ (fetch)"),
+		actual,
 	)
 }
 
 // https://github.com/seachicken/gh-poi/issues/152
 func Test_CreateRemoteWithScpLikeUrlTrailingSlash(t *testing.T) {
+	actual, _ := parseRemotes("origin	git@github.com:org/repo.git/ (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -124,12 +144,14 @@ func Test_CreateRemoteWithScpLikeUrlTrailingSlash(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	git@github.com:org/repo.git/ (fetch)"),
+		actual,
 	)
 }
 
 // https://github.com/seachicken/gh-poi/issues/39
 func Test_CreateRemoteWithCustomHostname(t *testing.T) {
+	actual, _ := parseRemotes("origin	git@github.com-work:org/repo.git (fetch)")
+
 	assert.Equal(t,
 		[]shared.Remote{
 			{
@@ -138,7 +160,83 @@ func Test_CreateRemoteWithCustomHostname(t *testing.T) {
 				RepoName: "org/repo",
 			},
 		},
-		parseRemotes("origin	git@github.com-work:org/repo.git (fetch)"),
+		actual,
+	)
+}
+
+func Test_CreateRemotePrefersFetchUrl(t *testing.T) {
+	actual, _ := parseRemotes("origin	git@push.example.com:org/repo (push)\norigin	git@github.com:org/repo (fetch)")
+
+	assert.Equal(t,
+		[]shared.Remote{
+			{
+				Name:     "origin",
+				Hostname: "github.com",
+				RepoName: "org/repo",
+			},
+		},
+		actual,
+	)
+}
+
+func Test_CreateRemoteWithPartialCloneFilter(t *testing.T) {
+	actual, _ := parseRemotes("origin	git@github.com:org/repo.git (fetch) [blob:none]")
+
+	assert.Equal(t,
+		[]shared.Remote{
+			{
+				Name:     "origin",
+				Hostname: "github.com",
+				RepoName: "org/repo",
+			},
+		},
+		actual,
+	)
+}
+
+func Test_SkipsUnsupportedRemotes(t *testing.T) {
+	actual, skipped := parseRemotes(
+		"local	/path/to/repo (fetch)\n" +
+			"mirror	file:///path/to/repo.git (fetch)\n" +
+			"origin	git@github.com:org/repo.git (fetch)\n" +
+			"broken\n")
+
+	assert.Equal(t,
+		[]shared.Remote{
+			{
+				Name:     "origin",
+				Hostname: "github.com",
+				RepoName: "org/repo",
+			},
+		},
+		actual,
+	)
+	assert.Equal(t, 3, len(skipped))
+	assert.Equal(t, "broken", skipped[0].Name)
+	assert.Equal(t, "local", skipped[1].Name)
+	assert.Equal(t, "/path/to/repo", skipped[1].Url)
+	assert.Equal(t, "mirror", skipped[2].Name)
+}
+
+func Test_ParseRefs(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "refs", "issue1_originMain")
+	assert.Equal(t,
+		[]shared.Ref{
+			{Name: "refs/heads/issue1", Oid: "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0"},
+			{Name: "refs/remotes/origin/main", Oid: "6ebe3d30d23531af56bd23b5a098d3ccae2a534a"},
+		},
+		parseRefs(stub),
+	)
+}
+
+func Test_ParseCommitGraph(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "graph", "issue1CommitAfterMerge")
+	assert.Equal(t,
+		[][]string{
+			{"b8a2645298053fb62ea03e27feea6c483d3fd27e", "a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0"},
+			{"a97e9630426df5d34ca9ee77ae1159bdfd5ff8f0", "6ebe3d30d23531af56bd23b5a098d3ccae2a534a"},
+		},
+		parseCommitGraph(stub),
 	)
 }
 
@@ -151,6 +249,99 @@ func Test_ParseUncommittedChanges(t *testing.T) {
 	)
 }
 
+func Test_ReadOperations(t *testing.T) {
+	t.Run("no operations", func(t *testing.T) {
+		assert.Equal(t, "", readOperations(t.TempDir()))
+	})
+
+	t.Run("rebase and merge", func(t *testing.T) {
+		gitDir := t.TempDir()
+		os.MkdirAll(filepath.Join(gitDir, "rebase-merge"), 0o755)
+		os.WriteFile(filepath.Join(gitDir, "rebase-merge", "head-name"), []byte("refs/heads/issue1\n"), 0o644)
+		os.WriteFile(filepath.Join(gitDir, "MERGE_HEAD"), []byte("6ebe3d30d23531af56bd23b5a098d3ccae2a534a\n"), 0o644)
+
+		assert.Equal(t, "rebase refs/heads/issue1\nmerge\n", readOperations(gitDir))
+	})
+
+	t.Run("bisect", func(t *testing.T) {
+		gitDir := t.TempDir()
+		os.WriteFile(filepath.Join(gitDir, "BISECT_START"), []byte("issue1\n"), 0o644)
+
+		assert.Equal(t, "bisect refs/heads/issue1\n", readOperations(gitDir))
+	})
+}
+
+func Test_ParseOperations(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "operation", "rebaseIssue1")
+	assert.Equal(t,
+		[]shared.Operation{{Kind: "rebase", BranchName: "issue1"}},
+		parseOperations(stub),
+	)
+}
+
+func Test_ParsePatchIds(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "patchId", "issue1CommitAfterMerge")
+	assert.Equal(t,
+		[]string{"5f0e5d2c43a4b8b1d0a8e6b0b3f1c9a7d2e4f6a8", "0c7d8b1e2f3a4b5c6d7e8f9a0b1c2d3e4f5a6b7c"},
+		parsePatchIds(stub),
+	)
+}
+
+func Test_ParseReverts(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "revert", "issue1Reapplied")
+	assert.Equal(t,
+		[]shared.Revert{
+			{
+				Oid:             "8c9e7c0b3e5b1a4c5f6d2f0e1b7a9d3c4e5f6a7b",
+				RevertedOids:    []string{"6ebe3d30d23531af56bd23b5a098d3ccae2a534a"},
+				RevertedNumbers: []int{1},
+				IsReapply:       false,
+			},
+			{
+				Oid:             "3f1d2e4c5b6a79808d7e6f5a4b3c2d1e0f9a8b7c",
+				RevertedOids:    []string{"8c9e7c0b3e5b1a4c5f6d2f0e1b7a9d3c4e5f6a7b"},
+				RevertedNumbers: []int{1},
+				IsReapply:       true,
+			},
+		},
+		parseReverts(stub),
+	)
+}
+
+func Test_ParseRevertsOfRevertPR(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "revert", "issue1RevertPR")
+	assert.Equal(t,
+		[]shared.Revert{
+			{
+				Oid:             "5d4c3b2a1f0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c",
+				RevertedOids:    []string{},
+				RevertedNumbers: []int{1},
+				IsReapply:       false,
+			},
+		},
+		parseReverts(stub),
+	)
+}
+
+func Test_ParseStashedBranchNames(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "stash", "issue1")
+	assert.Equal(t,
+		[]string{"issue1", "main"},
+		parseStashedBranchNames(stub),
+	)
+}
+
+func Test_ParseSubmodules(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "submodule", "lib")
+	assert.Equal(t,
+		[]shared.Submodule{
+			{Path: "libs/lib", IsInitialized: true},
+			{Path: "libs/uninitialized", IsInitialized: false},
+		},
+		parseSubmodules(stub),
+	)
+}
+
 func Test_ParseWorktreesWithLinkedWorktree(t *testing.T) {
 	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "worktree", "@main_+linkedIssue1")
 	assert.Equal(t,
@@ -194,6 +385,17 @@ func Test_ParseWorktreesWithLocked(t *testing.T) {
 	)
 }
 
+func Test_ParseWorktreesWithBare(t *testing.T) {
+	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "worktree", "bare_+linkedIssue1")
+	assert.Equal(t,
+		[]shared.Worktree{
+			{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_bare.git", Branch: "", IsMain: true, IsLocked: false, IsPrunable: false, IsBare: true},
+			{Path: "/home/runner/work/gh-poi/gh-poi/conn/fixtures/repo_worktree_linkedIssue1", Branch: "linkedIssue1", IsMain: false, IsLocked: false, IsPrunable: false},
+		},
+		parseWorktrees(stub),
+	)
+}
+
 func Test_ParseWorktreesWithPrunable(t *testing.T) {
 	stub := (&Stub{Conn: nil, T: t}).ReadFile("git", "worktree", "prunable")
 	assert.Equal(t,
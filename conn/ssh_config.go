@@ -0,0 +1,189 @@
+package conn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ssh limits the depth of nested Include directives to 16.
+const maxSshIncludeDepth = 16
+
+var errUnsupportedSshConfig = errors.New("unsupported ssh_config directive")
+
+type sshConfigFile struct {
+	path string
+	// Relative paths in Include directives are resolved from this directory.
+	includeDir string
+}
+
+// Returns the user and system ssh_config files in the order that ssh reads them.
+func defaultSshConfigFiles() []sshConfigFile {
+	files := []sshConfigFile{}
+	if home, err := os.UserHomeDir(); err == nil {
+		sshDir := filepath.Join(home, ".ssh")
+		files = append(files, sshConfigFile{path: filepath.Join(sshDir, "config"), includeDir: sshDir})
+	}
+	return append(files, sshConfigFile{path: "/etc/ssh/ssh_config", includeDir: "/etc/ssh"})
+}
+
+// Resolves the hostname of a host alias from ssh_config files without running `ssh -G`.
+// Only `Host`, `Include` and `Hostname` are interpreted, and the first obtained value is used as ssh does.
+// `Match` blocks depend on conditions that cannot be evaluated in-process (e.g. `Match exec`),
+// so errUnsupportedSshConfig is returned when one appears before the hostname is found.
+//
+// ref. https://man.openbsd.org/ssh_config
+func resolveSshHostname(host string, files []sshConfigFile) (string, error) {
+	for _, file := range files {
+		hostname, found, err := readSshHostname(host, file.path, file.includeDir, 0)
+		if err != nil {
+			return "", err
+		}
+		if found {
+			return hostname, nil
+		}
+	}
+	return host, nil
+}
+
+func readSshHostname(host string, path string, includeDir string, depth int) (string, bool, error) {
+	if depth > maxSshIncludeDepth {
+		return "", false, fmt.Errorf("too many nested includes: %s", path)
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	// Directives before the first Host line apply to all hosts
+	active := true
+	for _, line := range splitLines(string(b)) {
+		keyword, args := parseSshConfigLine(line)
+		switch keyword {
+		case "host":
+			active = matchSshHostPatterns(host, args)
+		case "match":
+			return "", false, fmt.Errorf("%w: Match in %s", errUnsupportedSshConfig, path)
+		case "include":
+			if !active {
+				continue
+			}
+			for _, arg := range args {
+				paths, err := filepath.Glob(expandSshPath(arg, includeDir))
+				if err != nil {
+					return "", false, err
+				}
+				for _, p := range paths {
+					hostname, found, err := readSshHostname(host, p, includeDir, depth+1)
+					if err != nil || found {
+						return hostname, found, err
+					}
+				}
+			}
+		case "hostname":
+			if active && len(args) > 0 {
+				return expandSshHostname(args[0], host), true, nil
+			}
+		}
+	}
+	return "", false, nil
+}
+
+// Splits a line into a lowercased keyword and its arguments.
+// The keyword may be separated by whitespace or an optional `=`, and arguments may be quoted.
+func parseSshConfigLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimPrefix(rest, "=")
+
+	args := []string{}
+	var arg strings.Builder
+	inQuote := false
+	for _, c := range rest {
+		switch {
+		case c == '"':
+			inQuote = !inQuote
+		case (c == ' ' || c == '\t') && !inQuote:
+			if arg.Len() > 0 {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+		default:
+			arg.WriteRune(c)
+		}
+	}
+	if arg.Len() > 0 {
+		args = append(args, arg.String())
+	}
+	return keyword, args
+}
+
+// Reports whether the host matches the pattern list of a Host line.
+// A host matching a negated pattern (`!pattern`) never matches, regardless of the other patterns.
+func matchSshHostPatterns(host string, patterns []string) bool {
+	host = strings.ToLower(host)
+	matched := false
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if negated, ok := strings.CutPrefix(pattern, "!"); ok {
+			if matchWildcard(host, negated) {
+				return false
+			}
+		} else if matchWildcard(host, pattern) {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// Matches `*` against any sequence of characters and `?` against exactly one character.
+func matchWildcard(s string, pattern string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if matchWildcard(s[i:], pattern[1:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && matchWildcard(s[1:], pattern[1:])
+	default:
+		return s != "" && s[0] == pattern[0] && matchWildcard(s[1:], pattern[1:])
+	}
+}
+
+func expandSshPath(path string, includeDir string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		return filepath.Join(includeDir, path)
+	}
+	return path
+}
+
+func expandSshHostname(hostname string, host string) string {
+	return strings.NewReplacer("%h", host, "%%", "%").Replace(hostname)
+}
@@ -0,0 +1,61 @@
+package conn
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sshConfigFixture(name string) []sshConfigFile {
+	_, filename, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filename, "..", fixturePath, "ssh")
+	return []sshConfigFile{{path: filepath.Join(dir, "config_"+name+".txt"), includeDir: dir}}
+}
+
+func Test_ResolveSshHostname(t *testing.T) {
+	files := sshConfigFixture("alias")
+
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"work", "github.com"},
+		{"WORK", "github.com"},
+		{"work-legacy", "work-legacy"},
+		{"ghe.example.com", "ghe.example.com.internal"},
+		{"quoted alias", "quoted.example.com"},
+		{"gh-enterprise", "ghe.example.com"},
+		{"gh-other", "gh-other"},
+		{"github.com", "github.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			actual, err := resolveSshHostname(tt.host, files)
+
+			assert.Nil(t, err)
+			assert.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func Test_ResolveSshHostnameWithMatch(t *testing.T) {
+	_, err := resolveSshHostname("work", sshConfigFixture("match"))
+
+	assert.ErrorIs(t, err, errUnsupportedSshConfig)
+}
+
+func Test_ResolveSshHostnameWithoutConfig(t *testing.T) {
+	actual, err := resolveSshHostname("work", sshConfigFixture("notFound"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "work", actual)
+}
+
+func Test_MatchSshHostPatterns(t *testing.T) {
+	assert.True(t, matchSshHostPatterns("github.com", []string{"*"}))
+	assert.True(t, matchSshHostPatterns("github.com", []string{"github.co?"}))
+	assert.False(t, matchSshHostPatterns("github.com", []string{"*", "!github.com"}))
+	assert.False(t, matchSshHostPatterns("github.com", []string{"!gitlab.com"}))
+}
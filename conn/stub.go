@@ -1,11 +1,13 @@
 package conn
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"runtime"
 
 	"github.com/seachicken/gh-poi/mocks"
+	"github.com/seachicken/gh-poi/shared"
 	"go.uber.org/mock/gomock"
 )
 
@@ -43,16 +45,21 @@ type (
 		Filename   string
 	}
 
-	AssociatedBranchNamesStub struct {
-		Oid      string
-		Filename string
-	}
-
 	UncommittedChangeStub struct {
 		Path   string
 		Output string
 	}
 
+	OperationStub struct {
+		Path     string
+		Filename string
+	}
+
+	SubmoduleStub struct {
+		Path     string
+		Filename string
+	}
+
 	LogStub struct {
 		BranchName string
 		Filename   string
@@ -114,7 +121,7 @@ func (s *Stub) GetRepoNames(stubs []RepoNamesStub, err error, conf *Conf) *Stub
 			s.Conn.
 				EXPECT().
 				GetRepoNames(gomock.Any(), gomock.Any(), stub.RepoName).
-				Return(s.ReadFile("gh", "repo", stub.Filename), err),
+				Return(s.ReadRepository(stub.Filename), err),
 			conf,
 		)
 	}
@@ -143,16 +150,58 @@ func (s *Stub) GetMergedBranchNames(filename string, err error, conf *Conf) *Stu
 	return s
 }
 
-func (s *Stub) GetAssociatedRefNames(stubs []AssociatedBranchNamesStub, err error, conf *Conf) *Stub {
+func (s *Stub) GetRefs(filename string, err error, conf *Conf) *Stub {
 	s.T.Helper()
-	for _, stub := range stubs {
-		configure(
-			s.Conn.EXPECT().
-				GetAssociatedRefNames(gomock.Any(), stub.Oid).
-				Return(s.ReadFile("git", "abranch", stub.Filename), err),
-			conf,
-		)
-	}
+	configure(
+		s.Conn.EXPECT().
+			GetRefs(gomock.Any()).
+			Return(s.ReadFile("git", "refs", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetTree(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetTree(gomock.Any(), gomock.Any()).
+			Return(s.ReadFile("git", "tree", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetPatchIds(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetPatchIds(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(s.ReadFile("git", "patchId", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetCommitPatchIds(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetCommitPatchIds(gomock.Any(), gomock.Any()).
+			Return(s.ReadFile("git", "patchId", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetCommitGraph(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetCommitGraph(gomock.Any(), gomock.Any()).
+			Return(s.ReadFile("git", "graph", filename), err),
+		conf,
+	)
 	return s
 }
 
@@ -161,7 +210,7 @@ func (s *Stub) GetLog(stubs []LogStub, err error, conf *Conf) *Stub {
 	for _, stub := range stubs {
 		configure(
 			s.Conn.EXPECT().
-				GetLog(gomock.Any(), stub.BranchName).
+				GetLog(gomock.Any(), stub.BranchName, gomock.Any()).
 				Return(s.ReadFile("git", "log", stub.Filename), err),
 			conf,
 		)
@@ -169,13 +218,25 @@ func (s *Stub) GetLog(stubs []LogStub, err error, conf *Conf) *Stub {
 	return s
 }
 
+func (s *Stub) GetBranchProtections(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.
+			EXPECT().
+			GetBranchProtections(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(s.ReadBranchProtections(filename), err),
+		conf,
+	)
+	return s
+}
+
 func (s *Stub) GetPullRequests(filename string, err error, conf *Conf) *Stub {
 	s.T.Helper()
 	configure(
 		s.Conn.
 			EXPECT().
 			GetPullRequests(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-			Return(s.ReadFile("gh", "pr", filename), err),
+			Return(s.ReadPullRequests(filename), err),
 		conf,
 	)
 	return s
@@ -205,6 +266,70 @@ func (s *Stub) GetUncommittedChanges(stubs []UncommittedChangeStub, err error, c
 	return s
 }
 
+func (s *Stub) GetSubmodules(stubs []SubmoduleStub, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	for _, stub := range stubs {
+		if stub.Path == "" {
+			configure(
+				s.Conn.
+					EXPECT().
+					GetSubmodules(gomock.Any()).
+					Return(s.ReadFile("git", "submodule", stub.Filename), err),
+				conf,
+			)
+		} else {
+			configure(
+				s.Conn.
+					EXPECT().
+					GetSubmodules(gomock.Any(), "-C", stub.Path).
+					Return(s.ReadFile("git", "submodule", stub.Filename), err),
+				conf,
+			)
+		}
+	}
+	return s
+}
+
+func (s *Stub) GetStashes(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetStashes(gomock.Any()).
+			Return(s.ReadFile("git", "stash", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetReverts(filename string, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.EXPECT().
+			GetReverts(gomock.Any(), gomock.Any()).
+			Return(s.ReadFile("git", "revert", filename), err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) GetOperations(stubs []OperationStub, err error, conf *Conf) *Stub {
+	s.T.Helper()
+	for _, stub := range stubs {
+		var path any = stub.Path
+		if stub.Path == "" {
+			path = gomock.Any()
+		}
+		configure(
+			s.Conn.
+				EXPECT().
+				GetOperations(gomock.Any(), path).
+				Return(s.ReadFile("git", "operation", stub.Filename), err),
+			conf,
+		)
+	}
+	return s
+}
+
 func (s *Stub) GetConfig(stubs []ConfigStub, err error, conf *Conf) *Stub {
 	s.T.Helper()
 	for _, stub := range stubs {
@@ -243,6 +368,18 @@ func (s *Stub) CheckoutBranch(err error, conf *Conf) *Stub {
 	return s
 }
 
+func (s *Stub) CreateBranch(err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.
+			EXPECT().
+			CreateBranch(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("", err),
+		conf,
+	)
+	return s
+}
+
 func (s *Stub) DeleteBranches(err error, conf *Conf) *Stub {
 	s.T.Helper()
 	configure(
@@ -255,6 +392,42 @@ func (s *Stub) DeleteBranches(err error, conf *Conf) *Stub {
 	return s
 }
 
+func (s *Stub) RebaseBranch(err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.
+			EXPECT().
+			RebaseBranch(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("", err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) AbortRebase(err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.
+			EXPECT().
+			AbortRebase(gomock.Any()).
+			Return("", err),
+		conf,
+	)
+	return s
+}
+
+func (s *Stub) SetUpstream(err error, conf *Conf) *Stub {
+	s.T.Helper()
+	configure(
+		s.Conn.
+			EXPECT().
+			SetUpstream(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return("", err),
+		conf,
+	)
+	return s
+}
+
 func (s *Stub) GetWorktrees(filename string, err error, conf *Conf) *Stub {
 	s.T.Helper()
 	configure(
@@ -300,3 +473,33 @@ func (s *Stub) ReadFile(command string, category string, name string) string {
 	}
 	return string(b)
 }
+
+func (s *Stub) ReadRepository(name string) shared.Repository {
+	var resp repositoryResponse
+	if err := json.Unmarshal([]byte(s.ReadFile("gh", "repo", name)), &resp); err != nil {
+		s.T.Fatalf("%v", err)
+	}
+	return toRepository(resp)
+}
+
+func (s *Stub) ReadBranchProtections(name string) []shared.BranchProtection {
+	var resp branchProtectionResponse
+	if err := json.Unmarshal([]byte(s.ReadFile("gh", "protection", name)), &resp); err != nil {
+		s.T.Fatalf("%v", err)
+	}
+	return toBranchProtections(resp)
+}
+
+func (s *Stub) ReadPullRequests(name string) []shared.PullRequest {
+	var resp struct {
+		Data searchResponse
+	}
+	if err := json.Unmarshal([]byte(s.ReadFile("gh", "pr", name)), &resp); err != nil {
+		s.T.Fatalf("%v", err)
+	}
+	prs, err := toPullRequests(resp.Data)
+	if err != nil {
+		s.T.Fatalf("%v", err)
+	}
+	return prs
+}
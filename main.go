@@ -7,13 +7,20 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime"
 	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/fatih/color"
 	"github.com/seachicken/gh-poi/cmd"
 	"github.com/seachicken/gh-poi/cmd/lock"
+	"github.com/seachicken/gh-poi/cmd/restack"
 	"github.com/seachicken/gh-poi/conn"
 	"github.com/seachicken/gh-poi/shared"
 )
@@ -25,6 +32,9 @@ var (
 	red     = color.New(color.FgRed).SprintFunc()
 )
 
+// Each repository runs its own git processes in parallel, so only a few repositories are cleaned up at once.
+const maxConcurrentRepositories = 4
+
 type StateFlag string
 
 const (
@@ -85,15 +95,52 @@ func (s ScanFlag) toModel() shared.ScanMode {
 	}
 }
 
+// A flag that can be repeated, or given a comma-separated list, to collect several values.
+type ListFlag []string
+
+func (l *ListFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *ListFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
 func main() {
 	state := Merged
 	scan := Quick
 	var dryRun bool
 	var debug bool
+	var jobs int
+	var depth int
+	var repoDir string
+	var recursive string
+	var recurseSubmodules bool
+	var ignoreStashes bool
+	var salvage bool
+	var integrationBranches ListFlag
+	var trunkBranches ListFlag
+	var keepLabels ListFlag
 	flag.Var(&state, "state", "Specify the PR state to delete by {closed|merged}")
 	flag.Var(&scan, "scan", "Specify the scan mode by {quick|deep}")
 	flag.BoolVar(&dryRun, "dry-run", false, "Show branches to delete without actually deleting it")
 	flag.BoolVar(&debug, "debug", false, "Enable debug logs")
+	flag.IntVar(&jobs, "jobs", runtime.NumCPU(), "Specify the number of git commands and API calls to run in parallel")
+	flag.StringVar(&repoDir, "C", "", "Run as if poi was started in the specified directory instead of the current one")
+	flag.StringVar(&repoDir, "repo-dir", "", "Same as -C")
+	flag.StringVar(&recursive, "recursive", "", "Clean up every git repository under the directory, or the repository paths read from stdin with '-'")
+	flag.BoolVar(&recurseSubmodules, "recurse-submodules", false, "Also clean up the branches of initialized submodules")
+	flag.BoolVar(&salvage, "salvage", false, "Move the commits added after the PR was merged to a new <branch>-followup branch and delete the branch")
+	flag.BoolVar(&ignoreStashes, "ignore-stashes", false, "Delete branches even if stash entries were created on them")
+	flag.Var(&integrationBranches, "integration-branch", "Also delete branches whose PRs were merged into the branch besides the default branch; can be repeated and supports patterns such as 'release/*'")
+	flag.Var(&trunkBranches, "trunk", "Keep the long-lived branch and treat it like the default branch; can be repeated and supports patterns such as 'release/*'")
+	flag.Var(&keepLabels, "keep-label", "Keep branches whose PRs have the label even after they are merged; can be repeated")
+	flag.IntVar(&depth, "depth", 0, "Specify the number of commits per branch to inspect in deep scans (default: commits since the default branch)")
 	flag.Usage = func() {
 		fmt.Fprintf(color.Output, "%s\n\n", "Delete the merged local branches.")
 		fmt.Fprintf(color.Output, "%s\n", bold("USAGE"))
@@ -102,6 +149,7 @@ func main() {
 		fmt.Fprintf(color.Output, "%s\n", `
   lock:      Lock branches to prevent them from being deleted
   unlock:    Unlock branches to allow them to be deleted
+  restack:   Rebase branches stacked on merged branches onto the default branch
   protect:   (Deprecated) use 'lock' instead
   unprotect: (Deprecated) use 'unlock' instead
   `)
@@ -113,15 +161,22 @@ func main() {
 			}
 		})
 		flag.VisitAll(func(f *flag.Flag) {
-			fmt.Fprintf(color.Output, "  --%-*s %s\n", maxLen+2, f.Name, f.Usage)
+			prefix := "--"
+			if len(f.Name) == 1 {
+				prefix = "-"
+			}
+			fmt.Fprintf(color.Output, "  %s%-*s %s\n", prefix, maxLen+4-len(prefix), f.Name, f.Usage)
 		})
 		fmt.Println()
 	}
 	flag.Parse()
 	args := flag.Args()
+	connection := &conn.Connection{Debug: debug, Dir: repoDir}
 
-	if len(args) == 0 {
-		runMain(state, scan, dryRun, debug)
+	if len(args) == 0 && recursive != "" {
+		runRecursive(recursive, state, scan, dryRun, connection, cmd.Options{Jobs: jobs, Depth: depth, RecurseSubmodules: recurseSubmodules, IgnoreStashes: ignoreStashes, Salvage: salvage, IntegrationBranches: integrationBranches, TrunkBranches: trunkBranches, KeepLabels: keepLabels})
+	} else if len(args) == 0 {
+		runMain(state, scan, dryRun, connection, cmd.Options{Jobs: jobs, Depth: depth, RecurseSubmodules: recurseSubmodules, IgnoreStashes: ignoreStashes, Salvage: salvage, IntegrationBranches: integrationBranches, TrunkBranches: trunkBranches, KeepLabels: keepLabels})
 	} else {
 		subcmd, args := args[0], args[1:]
 		switch subcmd {
@@ -138,7 +193,7 @@ func main() {
 			if subcmd == "protect" {
 				fmt.Fprintln(os.Stderr, "warning: 'protect' is deprecated, please use 'lock' instead")
 			}
-			runLock(args, debug)
+			runLock(args, connection)
 		case "unlock", "unprotect":
 			unlockCmd := flag.NewFlagSet("unlock", flag.ExitOnError)
 			unlockCmd.Usage = func() {
@@ -152,14 +207,24 @@ func main() {
 			if subcmd == "unprotect" {
 				fmt.Fprintln(os.Stderr, "warning: 'unprotect' is deprecated, please use 'unlock' instead")
 			}
-			runUnlock(args, debug)
+			runUnlock(args, connection)
+		case "restack":
+			restackCmd := flag.NewFlagSet("restack", flag.ExitOnError)
+			restackCmd.BoolVar(&dryRun, "dry-run", dryRun, "Show branches to restack without actually rebasing them")
+			restackCmd.Usage = func() {
+				fmt.Fprintf(color.Output, "%s\n\n", "Rebase branches stacked on merged branches onto the default branch")
+				fmt.Fprintf(color.Output, "%s\n", bold("USAGE"))
+				fmt.Fprintf(color.Output, "  %s\n\n", "gh poi restack [--dry-run]")
+			}
+			restackCmd.Parse(args)
+			runRestack(state, scan, dryRun, connection, cmd.Options{Jobs: jobs, Depth: depth, IgnoreStashes: ignoreStashes, IntegrationBranches: integrationBranches, TrunkBranches: trunkBranches, KeepLabels: keepLabels})
 		default:
 			fmt.Fprintf(os.Stderr, "unknown command %q for poi\n", subcmd)
 		}
 	}
 }
 
-func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
+func runMain(state StateFlag, scan ScanFlag, dryRun bool, connection *conn.Connection, opts cmd.Options) {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -167,13 +232,12 @@ func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
 		fmt.Fprintf(color.Output, "%s\n", bold("== DRY RUN =="))
 	}
 
-	connection := &conn.Connection{Debug: debug}
 	sp := spinner.New(spinner.CharSets[14], 40*time.Millisecond)
 	defer sp.Stop()
 
 	fetchingMsg := " Fetching pull requests..."
 	sp.Suffix = fetchingMsg
-	if !debug {
+	if !connection.Debug {
 		sp.Start()
 	}
 	var fetchingErr error
@@ -184,7 +248,7 @@ func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
 		return
 	}
 
-	branches, fetchingErr := cmd.GetBranches(ctx, remotes, connection, state.toModel(), scan.toModel(), dryRun)
+	branches, fetchingErr := cmd.GetBranches(ctx, remotes, connection, state.toModel(), scan.toModel(), dryRun, opts)
 
 	sp.Stop()
 
@@ -202,7 +266,7 @@ func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
 		fmt.Fprintf(color.Output, "%s%s\n", hiBlack("-"), deletingMsg)
 	} else {
 		sp.Suffix = deletingMsg
-		if !debug {
+		if !connection.Debug {
 			sp.Restart()
 		}
 
@@ -224,6 +288,51 @@ func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
 
 	fmt.Println()
 
+	printResults(branches, dryRun)
+
+	if opts.RecurseSubmodules {
+		runSubmodules(ctx, state, scan, dryRun, connection, opts)
+	}
+}
+
+func runSubmodules(ctx context.Context, state StateFlag, scan ScanFlag, dryRun bool, connection *conn.Connection, opts cmd.Options) {
+	base := connection.Dir
+	if base == "" {
+		base = "."
+	}
+	dirs, err := cmd.GetSubmoduleDirs(ctx, connection, base)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	sp := spinner.New(spinner.CharSets[14], 40*time.Millisecond)
+	defer sp.Stop()
+
+	for _, dir := range dirs {
+		cleaningMsg := fmt.Sprintf(" Cleaning up submodule %s...", dir)
+		sp.Suffix = cleaningMsg
+		if !connection.Debug {
+			sp.Restart()
+		}
+
+		branches, err := cleanRepository(ctx, connection.WithDir(dir), state, scan, dryRun, opts)
+
+		sp.Stop()
+
+		if err != nil {
+			fmt.Fprintf(color.Output, "%s%s\n", red("✕"), cleaningMsg)
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		fmt.Fprintf(color.Output, "%s%s\n", green("✔"), cleaningMsg)
+		fmt.Println()
+
+		printResults(branches, dryRun)
+	}
+}
+
+func printResults(branches []shared.Branch, dryRun bool) {
 	var deletedStates []shared.BranchState
 	var notDeletedStates []shared.BranchState
 	if dryRun {
@@ -243,24 +352,226 @@ func runMain(state StateFlag, scan ScanFlag, dryRun bool, debug bool) {
 	fmt.Println()
 }
 
-func runLock(branchNames []string, debug bool) {
+type repositoryResult struct {
+	dir      string
+	branches []shared.Branch
+	err      error
+}
+
+func runRecursive(root string, state StateFlag, scan ScanFlag, dryRun bool, connection *conn.Connection, opts cmd.Options) {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	connection := &conn.Connection{Debug: debug}
+	var dirs []string
+	var err error
+	if root == "-" {
+		dirs, err = cmd.ReadRepositories(os.Stdin)
+	} else {
+		dirs, err = cmd.FindRepositories(root)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if opts.RecurseSubmodules {
+		dirs = withSubmoduleDirs(ctx, connection, dirs)
+	}
 
-	err := lock.LockBranches(ctx, branchNames, connection)
+	if dryRun {
+		fmt.Fprintf(color.Output, "%s\n", bold("== DRY RUN =="))
+	}
+
+	sp := spinner.New(spinner.CharSets[14], 40*time.Millisecond)
+	defer sp.Stop()
+
+	cleaningMsg := fmt.Sprintf(" Cleaning up %d repositories...", len(dirs))
+	sp.Suffix = cleaningMsg
+	if !connection.Debug {
+		sp.Start()
+	}
+
+	// Repositories share the API rate limit budget through the connection, and the limit of parallel jobs through the pool
+	opts.Pool = cmd.NewWorkerPool(opts.Jobs)
+	results := make([]repositoryResult, len(dirs))
+	sem := make(chan struct{}, maxConcurrentRepositories)
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, dir string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			branches, err := cleanRepository(ctx, connection.WithDir(dir), state, scan, dryRun, opts)
+			results[i] = repositoryResult{dir: dir, branches: branches, err: err}
+		}(i, dir)
+	}
+	wg.Wait()
+
+	sp.Stop()
+
+	if slices.ContainsFunc(results, func(result repositoryResult) bool { return result.err != nil }) {
+		fmt.Fprintf(color.Output, "%s%s\n", red("✕"), cleaningMsg)
+	} else {
+		fmt.Fprintf(color.Output, "%s%s\n", green("✔"), cleaningMsg)
+	}
+	fmt.Println()
+
+	printSummary(root, results, dryRun)
+}
+
+// Inserts the submodule directories of each repository right after it.
+// Repositories whose submodules cannot be listed are still cleaned up.
+func withSubmoduleDirs(ctx context.Context, connection *conn.Connection, dirs []string) []string {
+	results := []string{}
+	for _, dir := range dirs {
+		results = append(results, dir)
+		submoduleDirs, err := cmd.GetSubmoduleDirs(ctx, connection.WithDir(dir), dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: failed to list submodules of %s: %s\n", dir, err)
+			continue
+		}
+		results = append(results, submoduleDirs...)
+	}
+	return results
+}
+
+func cleanRepository(ctx context.Context, connection *conn.Connection, state StateFlag, scan ScanFlag, dryRun bool, opts cmd.Options) ([]shared.Branch, error) {
+	remotes, err := cmd.GetPreferredRemotes(ctx, connection, scan.toModel())
 	if err != nil {
+		return nil, err
+	}
+
+	branches, err := cmd.GetBranches(ctx, remotes, connection, state.toModel(), scan.toModel(), dryRun, opts)
+	if err != nil || dryRun {
+		return branches, err
+	}
+
+	branches, err = cmd.DeleteBranches(ctx, branches, connection)
+	connection.PruneRemoteBranches(ctx, remotes[0].Name)
+	connection.PruneWorktrees(ctx)
+	return branches, err
+}
+
+func printSummary(root string, results []repositoryResult, dryRun bool) {
+	deletedState := shared.Deleted
+	if dryRun {
+		deletedState = shared.Deletable
+	}
+
+	w := tabwriter.NewWriter(color.Output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPOSITORY\tDELETED\tKEPT\t")
+	for _, result := range results {
+		dir := result.dir
+		if rel, err := filepath.Rel(root, result.dir); err == nil && root != "-" {
+			dir = rel
+		}
+		if result.err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\terror: %s\n", dir, strings.ReplaceAll(result.err.Error(), "\n", " "))
+			continue
+		}
+
+		deleted := []string{}
+		kept := 0
+		for _, branch := range result.branches {
+			if branch.State == deletedState {
+				deleted = append(deleted, branch.Name)
+			} else {
+				kept++
+			}
+		}
+		deletedColumn := strconv.Itoa(len(deleted))
+		if len(deleted) > 0 {
+			deletedColumn += " (" + strings.Join(deleted, ", ") + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t\n", dir, deletedColumn, kept)
+	}
+	w.Flush()
+	fmt.Println()
+}
+
+func runRestack(state StateFlag, scan ScanFlag, dryRun bool, connection *conn.Connection, opts cmd.Options) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if dryRun {
+		fmt.Fprintf(color.Output, "%s\n", bold("== DRY RUN =="))
+	}
+
+	sp := spinner.New(spinner.CharSets[14], 40*time.Millisecond)
+	defer sp.Stop()
+
+	fetchingMsg := " Fetching pull requests..."
+	sp.Suffix = fetchingMsg
+	if !connection.Debug {
+		sp.Start()
+	}
+
+	remotes, err := cmd.GetPreferredRemotes(ctx, connection, scan.toModel())
+	if err != nil {
+		sp.Stop()
 		fmt.Fprintln(os.Stderr, err)
 		return
 	}
+	// Branches are only inspected here, so the current branch is never switched
+	branches, err := cmd.GetBranches(ctx, remotes, connection, state.toModel(), scan.toModel(), true, opts)
+
+	sp.Stop()
+
+	if err != nil {
+		fmt.Fprintf(color.Output, "%s%s\n", red("✕"), fetchingMsg)
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(color.Output, "%s%s\n", green("✔"), fetchingMsg)
+
+	restackingMsg := " Restacking branches..."
+	sp.Suffix = restackingMsg
+	if !connection.Debug && !dryRun {
+		sp.Restart()
+	}
+
+	results, restackingErr := restack.RestackBranches(ctx, remotes, branches, state.toModel(), connection, dryRun)
+
+	sp.Stop()
+
+	if dryRun {
+		fmt.Fprintf(color.Output, "%s%s\n", hiBlack("-"), restackingMsg)
+	} else if restackingErr == nil {
+		fmt.Fprintf(color.Output, "%s%s\n", green("✔"), restackingMsg)
+	} else {
+		fmt.Fprintf(color.Output, "%s%s\n", red("✕"), restackingMsg)
+	}
+	fmt.Println()
+
+	fmt.Fprintf(color.Output, "%s\n", bold("Restacked branches"))
+	if len(results) == 0 {
+		fmt.Fprintf(color.Output, "%s\n", hiBlack("  There are no branches stacked on merged branches"))
+	}
+	for _, result := range results {
+		fmt.Fprintf(color.Output, "  %s %s\n", result.BranchName, hiBlack("(onto "+result.Onto+")"))
+	}
+	fmt.Println()
+
+	if restackingErr != nil {
+		fmt.Fprintln(os.Stderr, restackingErr)
+	}
 }
 
-func runUnlock(branchNames []string, debug bool) {
+func runLock(branchNames []string, connection *conn.Connection) {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
-	connection := &conn.Connection{Debug: debug}
+	err := lock.LockBranches(ctx, branchNames, connection)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+}
+
+func runUnlock(branchNames []string, connection *conn.Connection) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
 	err := lock.UnlockBranches(ctx, branchNames, connection)
 	if err != nil {
@@ -275,57 +586,142 @@ func printBranches(branches []shared.Branch) {
 			hiBlack("  There are no branches in the current directory"))
 	}
 
+	// Branches stacked on another listed branch are printed under it
+	childNames := map[string][]string{}
+	isChild := map[string]bool{}
 	for _, branch := range branches {
-		if branch.Head {
-			fmt.Fprintf(color.Output, "* %s", green(branch.Name))
-		} else {
-			fmt.Fprintf(color.Output, "  %s", branch.Name)
+		for _, name := range cmd.GetDirectChildNames(branch, branches) {
+			if cmd.BranchNameExists(name, branches) {
+				childNames[branch.Name] = append(childNames[branch.Name], name)
+				isChild[name] = true
+			}
 		}
+	}
 
-		// Show worktree info for any branch with an associated worktree
-		if branch.Worktree != nil && !branch.Worktree.IsMain {
-			fmt.Fprintf(color.Output, " %s", hiBlack("(worktree: "+branch.Worktree.Path+")"))
+	// Upstream configs can stack branches on each other in a cycle, so each branch is printed only once
+	printed := map[string]bool{}
+	var printTree func(branch shared.Branch, depth int)
+	printTree = func(branch shared.Branch, depth int) {
+		printed[branch.Name] = true
+		printBranch(branch, depth)
+		for _, child := range branches {
+			if slices.Contains(childNames[branch.Name], child.Name) && !printed[child.Name] {
+				printTree(child, depth+1)
+			}
+		}
+	}
+	for _, branch := range branches {
+		if !isChild[branch.Name] {
+			printTree(branch, 0)
+		}
+	}
+	// Branches in a cycle are never reached from a root, so they are printed from the first of them
+	for _, branch := range branches {
+		if !printed[branch.Name] {
+			printTree(branch, 0)
 		}
+	}
+}
 
-		reason := ""
-		if branch.State == shared.NotDeletable {
-			if branch.IsLocked {
-				reason = "locked"
-			} else if branch.Worktree != nil && branch.Worktree.IsLocked {
-				reason = "worktree locked"
-			} else if branch.Worktree != nil && branch.Worktree.IsMain && !branch.Head {
-				reason = "main worktree"
-			} else if branch.Worktree != nil && !branch.Worktree.IsMain && branch.Head {
-				reason = "worktree here"
-			} else if branch.Worktree != nil && branch.HasUntrackedFiles {
-				reason = "untracked files"
-			} else if !branch.IsDefault && len(branch.PullRequests) > 0 && branch.HasTrackedChanges {
-				reason = "uncommitted changes"
-			}
+func printBranch(branch shared.Branch, depth int) {
+	mark := "  "
+	name := branch.Name
+	if branch.Head {
+		mark = "* "
+		name = green(branch.Name)
+	}
+	indent := strings.Repeat("  ", depth)
+	if depth > 0 {
+		name = strings.Repeat("  ", depth-1) + hiBlack("↳ ") + name
+	}
+	fmt.Fprintf(color.Output, "%s%s", mark, name)
+
+	// Show the PR numbers on the branch line so that the PRs in a stack can be followed at a glance
+	if (depth > 0 || len(branch.StackedBranchNames) > 0) && len(branch.PullRequests) > 0 {
+		numbers := []string{}
+		for _, pr := range branch.PullRequests {
+			numbers = append(numbers, color.New(getIssueNoColor(pr.State, pr.IsDraft)).Sprintf("#%v", pr.Number))
 		}
-		if reason == "" {
-			fmt.Fprintln(color.Output, "")
-		} else {
-			fmt.Fprintf(color.Output, " %s\n", hiBlack("["+reason+"]"))
+		fmt.Fprintf(color.Output, " %s", strings.Join(numbers, " "))
+	}
+
+	// Show worktree info for any branch with an associated worktree
+	if branch.Worktree != nil && !branch.Worktree.IsMain {
+		fmt.Fprintf(color.Output, " %s", hiBlack("(worktree: "+branch.Worktree.Path+")"))
+	}
+
+	reason := ""
+	if branch.State == shared.NotDeletable {
+		if branch.IsLocked {
+			reason = "locked"
+		} else if branch.IsProtectedOnGitHub {
+			reason = "protected on GitHub"
+		} else if branch.IsTrunk {
+			reason = "trunk"
+		} else if branch.Worktree != nil && branch.Worktree.IsLocked {
+			reason = "worktree locked"
+		} else if branch.Worktree != nil && branch.Worktree.IsBare {
+			reason = "bare repository"
+		} else if branch.Worktree != nil && branch.Worktree.IsMain && !branch.Head {
+			reason = "main worktree"
+		} else if branch.Worktree != nil && !branch.Worktree.IsMain && branch.Head {
+			reason = "worktree here"
+		} else if branch.Worktree != nil && branch.HasUntrackedFiles {
+			reason = "untracked files"
+		} else if !branch.IsDefault && branch.HasModifiedSubmodules {
+			reason = "modified submodules"
+		} else if branch.Operation != "" {
+			reason = branch.Operation + " in progress"
+		} else if !branch.IsDefault && len(branch.PullRequests) > 0 && branch.HasTrackedChanges {
+			reason = "uncommitted changes"
+		} else if !branch.IsDefault && len(branch.PullRequests) > 0 && branch.HasStashes {
+			reason = "stashed changes"
+		} else if slices.ContainsFunc(branch.PullRequests, func(pr shared.PullRequest) bool { return pr.IsReverted }) {
+			reason = "merge reverted"
+		} else if i := slices.IndexFunc(branch.PullRequests, func(pr shared.PullRequest) bool { return pr.KeepLabel != "" }); i >= 0 {
+			reason = "labeled " + branch.PullRequests[i].KeepLabel
+		} else if i := slices.IndexFunc(branch.PullRequests, func(pr shared.PullRequest) bool { return pr.IsMergedElsewhere }); i >= 0 {
+			reason = "merged into " + branch.PullRequests[i].BaseRefName
+		} else if !branch.IsDefault && len(branch.StackedBranchNames) > 0 {
+			reason = "stacked branches: " + strings.Join(branch.StackedBranchNames, ", ")
 		}
+	}
+	if branch.FollowupBranchName != "" {
+		fmt.Fprintf(color.Output, " %s", hiBlack("(salvaged to "+branch.FollowupBranchName+")"))
+	}
+	if branch.IsTruncated {
+		fmt.Fprintf(color.Output, " %s", hiBlack(fmt.Sprintf("(history limited to %d commits)", len(branch.Commits))))
+	}
+	if reason == "" {
+		fmt.Fprintln(color.Output, "")
+	} else {
+		fmt.Fprintf(color.Output, " %s\n", hiBlack("["+reason+"]"))
+	}
 
-		for i, pr := range branch.PullRequests {
-			number := fmt.Sprintf("#%v", pr.Number)
-			issueNoColor := getIssueNoColor(pr.State, pr.IsDraft)
-			var line string
-			if i == len(branch.PullRequests)-1 {
-				line = "└─"
-			} else {
-				line = "├─"
-			}
+	for i, pr := range branch.PullRequests {
+		number := fmt.Sprintf("#%v", pr.Number)
+		issueNoColor := getIssueNoColor(pr.State, pr.IsDraft)
+		var line string
+		if i == len(branch.PullRequests)-1 {
+			line = "└─"
+		} else {
+			line = "├─"
+		}
 
-			fmt.Fprintf(color.Output, "    %s %s  %s %s\n",
-				line,
-				color.New(issueNoColor).SprintFunc()(number),
-				pr.Url,
-				hiBlack(pr.Author),
-			)
+		author := pr.Author
+		if pr.HeadRepoName != "" {
+			author += " (" + pr.HeadRepoName + ")"
+		}
+		if pr.MatchedBy != "" {
+			author += " (matched by " + pr.MatchedBy + ")"
 		}
+		fmt.Fprintf(color.Output, "    %s%s %s  %s %s\n",
+			indent,
+			line,
+			color.New(issueNoColor).SprintFunc()(number),
+			pr.Url,
+			hiBlack(author),
+		)
 	}
 }
 
@@ -10,13 +10,15 @@ import (
 	"testing"
 
 	"github.com/fatih/color"
+	"github.com/seachicken/gh-poi/cmd"
+	"github.com/seachicken/gh-poi/conn"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestE2E_DeletingBranchesWhenDryRunOptionIsFalse(t *testing.T) {
 	onlyCI(t)
 
-	results := captureOutput(func() { runMain(Merged, Quick, false, false) })
+	results := captureOutput(func() { runMain(Merged, Quick, false, &conn.Connection{}, cmd.Options{}) })
 
 	expected := fmt.Sprintf("%s %s", green("✔"), "Deleting branches...")
 	assert.Contains(t, results, expected)
@@ -25,7 +27,7 @@ func TestE2E_DeletingBranchesWhenDryRunOptionIsFalse(t *testing.T) {
 func TestE2E_DoNotDeleteBranchesWhenDryRunOptionIsTrue(t *testing.T) {
 	onlyCI(t)
 
-	results := captureOutput(func() { runMain(Merged, Quick, true, false) })
+	results := captureOutput(func() { runMain(Merged, Quick, true, &conn.Connection{}, cmd.Options{}) })
 
 	expected := fmt.Sprintf("%s %s", hiBlack("-"), "Deleting branches...")
 	assert.Contains(t, results, expected)
@@ -34,13 +36,13 @@ func TestE2E_DoNotDeleteBranchesWhenDryRunOptionIsTrue(t *testing.T) {
 func TestE2E_LockAndUnlock(t *testing.T) {
 	onlyCI(t)
 
-	runLock([]string{"main"}, false)
-	lockResults := captureOutput(func() { runMain(Merged, Quick, true, false) })
+	runLock([]string{"main"}, &conn.Connection{})
+	lockResults := captureOutput(func() { runMain(Merged, Quick, true, &conn.Connection{}, cmd.Options{}) })
 	expected := fmt.Sprintf("main %s", hiBlack("[locked]"))
 	assert.Contains(t, lockResults, expected)
 
-	runUnlock([]string{"main"}, false)
-	unlockResults := captureOutput(func() { runMain(Merged, Quick, true, false) })
+	runUnlock([]string{"main"}, &conn.Connection{})
+	unlockResults := captureOutput(func() { runMain(Merged, Quick, true, &conn.Connection{}, cmd.Options{}) })
 	assert.NotContains(t, unlockResults, expected)
 }
 
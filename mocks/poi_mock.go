@@ -13,6 +13,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	shared "github.com/seachicken/gh-poi/shared"
 	gomock "go.uber.org/mock/gomock"
 )
 
@@ -40,6 +41,21 @@ func (m *MockConnection) EXPECT() *MockConnectionMockRecorder {
 	return m.recorder
 }
 
+// AbortRebase mocks base method.
+func (m *MockConnection) AbortRebase(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AbortRebase", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AbortRebase indicates an expected call of AbortRebase.
+func (mr *MockConnectionMockRecorder) AbortRebase(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AbortRebase", reflect.TypeOf((*MockConnection)(nil).AbortRebase), ctx)
+}
+
 // AddConfig mocks base method.
 func (m *MockConnection) AddConfig(ctx context.Context, key, value string) (string, error) {
 	m.ctrl.T.Helper()
@@ -70,6 +86,21 @@ func (mr *MockConnectionMockRecorder) CheckoutBranch(ctx, branchName, detach any
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutBranch", reflect.TypeOf((*MockConnection)(nil).CheckoutBranch), ctx, branchName, detach)
 }
 
+// CreateBranch mocks base method.
+func (m *MockConnection) CreateBranch(ctx context.Context, branchName, startPoint string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBranch", ctx, branchName, startPoint)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBranch indicates an expected call of CreateBranch.
+func (mr *MockConnectionMockRecorder) CreateBranch(ctx, branchName, startPoint any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBranch", reflect.TypeOf((*MockConnection)(nil).CreateBranch), ctx, branchName, startPoint)
+}
+
 // DeleteBranches mocks base method.
 func (m *MockConnection) DeleteBranches(ctx context.Context, branchNames []string) (string, error) {
 	m.ctrl.T.Helper()
@@ -100,34 +131,64 @@ func (mr *MockConnectionMockRecorder) FetchBranch(ctx, remoteName, branchName an
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchBranch", reflect.TypeOf((*MockConnection)(nil).FetchBranch), ctx, remoteName, branchName)
 }
 
-// GetAssociatedRefNames mocks base method.
-func (m *MockConnection) GetAssociatedRefNames(ctx context.Context, oid string) (string, error) {
+// GetBranchNames mocks base method.
+func (m *MockConnection) GetBranchNames(ctx context.Context) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAssociatedRefNames", ctx, oid)
+	ret := m.ctrl.Call(m, "GetBranchNames", ctx)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAssociatedRefNames indicates an expected call of GetAssociatedRefNames.
-func (mr *MockConnectionMockRecorder) GetAssociatedRefNames(ctx, oid any) *gomock.Call {
+// GetBranchNames indicates an expected call of GetBranchNames.
+func (mr *MockConnectionMockRecorder) GetBranchNames(ctx any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAssociatedRefNames", reflect.TypeOf((*MockConnection)(nil).GetAssociatedRefNames), ctx, oid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBranchNames", reflect.TypeOf((*MockConnection)(nil).GetBranchNames), ctx)
 }
 
-// GetBranchNames mocks base method.
-func (m *MockConnection) GetBranchNames(ctx context.Context) (string, error) {
+// GetBranchProtections mocks base method.
+func (m *MockConnection) GetBranchProtections(ctx context.Context, hostname, repoName string) ([]shared.BranchProtection, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetBranchNames", ctx)
+	ret := m.ctrl.Call(m, "GetBranchProtections", ctx, hostname, repoName)
+	ret0, _ := ret[0].([]shared.BranchProtection)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetBranchProtections indicates an expected call of GetBranchProtections.
+func (mr *MockConnectionMockRecorder) GetBranchProtections(ctx, hostname, repoName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBranchProtections", reflect.TypeOf((*MockConnection)(nil).GetBranchProtections), ctx, hostname, repoName)
+}
+
+// GetCommitGraph mocks base method.
+func (m *MockConnection) GetCommitGraph(ctx context.Context, excludedRefNames []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommitGraph", ctx, excludedRefNames)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetBranchNames indicates an expected call of GetBranchNames.
-func (mr *MockConnectionMockRecorder) GetBranchNames(ctx any) *gomock.Call {
+// GetCommitGraph indicates an expected call of GetCommitGraph.
+func (mr *MockConnectionMockRecorder) GetCommitGraph(ctx, excludedRefNames any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBranchNames", reflect.TypeOf((*MockConnection)(nil).GetBranchNames), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommitGraph", reflect.TypeOf((*MockConnection)(nil).GetCommitGraph), ctx, excludedRefNames)
+}
+
+// GetCommitPatchIds mocks base method.
+func (m *MockConnection) GetCommitPatchIds(ctx context.Context, oids []string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCommitPatchIds", ctx, oids)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCommitPatchIds indicates an expected call of GetCommitPatchIds.
+func (mr *MockConnectionMockRecorder) GetCommitPatchIds(ctx, oids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCommitPatchIds", reflect.TypeOf((*MockConnection)(nil).GetCommitPatchIds), ctx, oids)
 }
 
 // GetConfig mocks base method.
@@ -146,18 +207,18 @@ func (mr *MockConnectionMockRecorder) GetConfig(ctx, key any) *gomock.Call {
 }
 
 // GetLog mocks base method.
-func (m *MockConnection) GetLog(ctx context.Context, branchName string) (string, error) {
+func (m *MockConnection) GetLog(ctx context.Context, branchName string, maxCount int) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLog", ctx, branchName)
+	ret := m.ctrl.Call(m, "GetLog", ctx, branchName, maxCount)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLog indicates an expected call of GetLog.
-func (mr *MockConnectionMockRecorder) GetLog(ctx, branchName any) *gomock.Call {
+func (mr *MockConnectionMockRecorder) GetLog(ctx, branchName, maxCount any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLog", reflect.TypeOf((*MockConnection)(nil).GetLog), ctx, branchName)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLog", reflect.TypeOf((*MockConnection)(nil).GetLog), ctx, branchName, maxCount)
 }
 
 // GetMergedBranchNames mocks base method.
@@ -175,11 +236,41 @@ func (mr *MockConnectionMockRecorder) GetMergedBranchNames(ctx, remoteName, bran
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMergedBranchNames", reflect.TypeOf((*MockConnection)(nil).GetMergedBranchNames), ctx, remoteName, branchName)
 }
 
+// GetOperations mocks base method.
+func (m *MockConnection) GetOperations(ctx context.Context, worktreePath string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOperations", ctx, worktreePath)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOperations indicates an expected call of GetOperations.
+func (mr *MockConnectionMockRecorder) GetOperations(ctx, worktreePath any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOperations", reflect.TypeOf((*MockConnection)(nil).GetOperations), ctx, worktreePath)
+}
+
+// GetPatchIds mocks base method.
+func (m *MockConnection) GetPatchIds(ctx context.Context, branchName, excludedRefName string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPatchIds", ctx, branchName, excludedRefName)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPatchIds indicates an expected call of GetPatchIds.
+func (mr *MockConnectionMockRecorder) GetPatchIds(ctx, branchName, excludedRefName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPatchIds", reflect.TypeOf((*MockConnection)(nil).GetPatchIds), ctx, branchName, excludedRefName)
+}
+
 // GetPullRequests mocks base method.
-func (m *MockConnection) GetPullRequests(ctx context.Context, hostname, orgs, repos, queryHashes string) (string, error) {
+func (m *MockConnection) GetPullRequests(ctx context.Context, hostname, orgs, repos, queryHashes string) ([]shared.PullRequest, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetPullRequests", ctx, hostname, orgs, repos, queryHashes)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].([]shared.PullRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -190,6 +281,21 @@ func (mr *MockConnectionMockRecorder) GetPullRequests(ctx, hostname, orgs, repos
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPullRequests", reflect.TypeOf((*MockConnection)(nil).GetPullRequests), ctx, hostname, orgs, repos, queryHashes)
 }
 
+// GetRefs mocks base method.
+func (m *MockConnection) GetRefs(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRefs", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRefs indicates an expected call of GetRefs.
+func (mr *MockConnectionMockRecorder) GetRefs(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRefs", reflect.TypeOf((*MockConnection)(nil).GetRefs), ctx)
+}
+
 // GetRemoteNames mocks base method.
 func (m *MockConnection) GetRemoteNames(ctx context.Context) (string, error) {
 	m.ctrl.T.Helper()
@@ -206,10 +312,10 @@ func (mr *MockConnectionMockRecorder) GetRemoteNames(ctx any) *gomock.Call {
 }
 
 // GetRepoNames mocks base method.
-func (m *MockConnection) GetRepoNames(ctx context.Context, hostname, repoName string) (string, error) {
+func (m *MockConnection) GetRepoNames(ctx context.Context, hostname, repoName string) (shared.Repository, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetRepoNames", ctx, hostname, repoName)
-	ret0, _ := ret[0].(string)
+	ret0, _ := ret[0].(shared.Repository)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -220,6 +326,21 @@ func (mr *MockConnectionMockRecorder) GetRepoNames(ctx, hostname, repoName any)
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepoNames", reflect.TypeOf((*MockConnection)(nil).GetRepoNames), ctx, hostname, repoName)
 }
 
+// GetReverts mocks base method.
+func (m *MockConnection) GetReverts(ctx context.Context, refName string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReverts", ctx, refName)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReverts indicates an expected call of GetReverts.
+func (mr *MockConnectionMockRecorder) GetReverts(ctx, refName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReverts", reflect.TypeOf((*MockConnection)(nil).GetReverts), ctx, refName)
+}
+
 // GetSshConfig mocks base method.
 func (m *MockConnection) GetSshConfig(ctx context.Context, name string) (string, error) {
 	m.ctrl.T.Helper()
@@ -235,6 +356,56 @@ func (mr *MockConnectionMockRecorder) GetSshConfig(ctx, name any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSshConfig", reflect.TypeOf((*MockConnection)(nil).GetSshConfig), ctx, name)
 }
 
+// GetStashes mocks base method.
+func (m *MockConnection) GetStashes(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStashes", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStashes indicates an expected call of GetStashes.
+func (mr *MockConnectionMockRecorder) GetStashes(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStashes", reflect.TypeOf((*MockConnection)(nil).GetStashes), ctx)
+}
+
+// GetSubmodules mocks base method.
+func (m *MockConnection) GetSubmodules(ctx context.Context, opts ...string) (string, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetSubmodules", varargs...)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubmodules indicates an expected call of GetSubmodules.
+func (mr *MockConnectionMockRecorder) GetSubmodules(ctx any, opts ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubmodules", reflect.TypeOf((*MockConnection)(nil).GetSubmodules), varargs...)
+}
+
+// GetTree mocks base method.
+func (m *MockConnection) GetTree(ctx context.Context, branchName string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTree", ctx, branchName)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTree indicates an expected call of GetTree.
+func (mr *MockConnectionMockRecorder) GetTree(ctx, branchName any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTree", reflect.TypeOf((*MockConnection)(nil).GetTree), ctx, branchName)
+}
+
 // GetUncommittedChanges mocks base method.
 func (m *MockConnection) GetUncommittedChanges(ctx context.Context, opts ...string) (string, error) {
 	m.ctrl.T.Helper()
@@ -270,6 +441,21 @@ func (mr *MockConnectionMockRecorder) GetWorktrees(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorktrees", reflect.TypeOf((*MockConnection)(nil).GetWorktrees), ctx)
 }
 
+// RebaseBranch mocks base method.
+func (m *MockConnection) RebaseBranch(ctx context.Context, branchName, upstream, onto string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RebaseBranch", ctx, branchName, upstream, onto)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RebaseBranch indicates an expected call of RebaseBranch.
+func (mr *MockConnectionMockRecorder) RebaseBranch(ctx, branchName, upstream, onto any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RebaseBranch", reflect.TypeOf((*MockConnection)(nil).RebaseBranch), ctx, branchName, upstream, onto)
+}
+
 // RemoveConfig mocks base method.
 func (m *MockConnection) RemoveConfig(ctx context.Context, key string) (string, error) {
 	m.ctrl.T.Helper()
@@ -299,3 +485,18 @@ func (mr *MockConnectionMockRecorder) RemoveWorktree(ctx, path any) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorktree", reflect.TypeOf((*MockConnection)(nil).RemoveWorktree), ctx, path)
 }
+
+// SetUpstream mocks base method.
+func (m *MockConnection) SetUpstream(ctx context.Context, branchName, upstream string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUpstream", ctx, branchName, upstream)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUpstream indicates an expected call of SetUpstream.
+func (mr *MockConnectionMockRecorder) SetUpstream(ctx, branchName, upstream any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUpstream", reflect.TypeOf((*MockConnection)(nil).SetUpstream), ctx, branchName, upstream)
+}
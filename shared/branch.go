@@ -8,17 +8,32 @@ type (
 	BranchState int
 
 	Branch struct {
-		Head              bool
-		Name              string
-		IsDefault         bool
-		IsMerged          bool
-		IsLocked          bool
-		HasTrackedChanges bool
-		HasUntrackedFiles bool
-		Commits           []string
-		PullRequests      []PullRequest
-		State             BranchState
-		Worktree          *Worktree
+		Head                  bool
+		Name                  string
+		IsDefault             bool
+		IsTrunk               bool
+		IsMerged              bool
+		IsLocked              bool
+		IsProtectedOnGitHub   bool
+		HasTrackedChanges     bool
+		HasUntrackedFiles     bool
+		HasModifiedSubmodules bool
+		HasStashes            bool
+		Operation             string
+		StackedBranchNames    []string
+		DeletedBase           *DeletedBase
+		FollowupBranchName    string
+		Commits               []string
+		IsTruncated           bool
+		PullRequests          []PullRequest
+		State                 BranchState
+		Worktree              *Worktree
+	}
+
+	// A local base branch that was deleted after its PR was merged, and the last of its commits the branch is built on.
+	DeletedBase struct {
+		Name string
+		Oid  string
 	}
 
 	UncommittedChange struct {
@@ -0,0 +1,65 @@
+package shared
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Branch name patterns protected on GitHub by a branch protection rule or a ruleset.
+// Besides fnmatch patterns, rulesets use `~DEFAULT_BRANCH` and `~ALL`.
+type BranchProtection struct {
+	Include []string
+	Exclude []string
+}
+
+func (p BranchProtection) Matches(branchName string, defaultBranchName string) bool {
+	matches := func(pattern string) bool {
+		switch pattern {
+		case "~ALL":
+			return true
+		case "~DEFAULT_BRANCH":
+			return branchName == defaultBranchName
+		}
+		return globToRegexp(pattern).MatchString(branchName)
+	}
+	return slices.ContainsFunc(p.Include, matches) && !slices.ContainsFunc(p.Exclude, matches)
+}
+
+// Converts a fnmatch pattern as GitHub uses it, where `*` does not match `/` but `**` does.
+func globToRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			if end := strings.IndexByte(pattern[i+1:], ']'); end > 0 {
+				class := strings.ReplaceAll(pattern[i+1:i+1+end], `\`, `\\`)
+				if negated, ok := strings.CutPrefix(class, "!"); ok {
+					class = "^" + negated
+				}
+				b.WriteString("[" + class + "]")
+				i += end + 1
+			} else {
+				b.WriteString(`\[`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+	return re
+}
@@ -0,0 +1,39 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BranchProtectionMatches(t *testing.T) {
+	t.Run("matches a single path segment with *", func(t *testing.T) {
+		protection := BranchProtection{Include: []string{"release/*"}}
+		assert.True(t, protection.Matches("release/1.0", "main"))
+		assert.False(t, protection.Matches("release/1.0/hotfix", "main"))
+		assert.False(t, protection.Matches("issue1", "main"))
+	})
+
+	t.Run("matches any path segments with **", func(t *testing.T) {
+		protection := BranchProtection{Include: []string{"release/**"}}
+		assert.True(t, protection.Matches("release/1.0/hotfix", "main"))
+	})
+
+	t.Run("matches character classes", func(t *testing.T) {
+		protection := BranchProtection{Include: []string{"v[0-9]", "[!a-z]x"}}
+		assert.True(t, protection.Matches("v1", "main"))
+		assert.False(t, protection.Matches("va", "main"))
+		assert.True(t, protection.Matches("1x", "main"))
+		assert.False(t, protection.Matches("ax", "main"))
+	})
+
+	t.Run("matches the default branch and all branches", func(t *testing.T) {
+		protection := BranchProtection{Include: []string{"~DEFAULT_BRANCH"}}
+		assert.True(t, protection.Matches("main", "main"))
+		assert.False(t, protection.Matches("issue1", "main"))
+
+		protection = BranchProtection{Include: []string{"~ALL"}, Exclude: []string{"issue*"}}
+		assert.True(t, protection.Matches("main", "main"))
+		assert.False(t, protection.Matches("issue1", "main"))
+	})
+}
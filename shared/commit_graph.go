@@ -0,0 +1,107 @@
+package shared
+
+import (
+	"regexp"
+	"slices"
+)
+
+type (
+	Ref struct {
+		Name string
+		Oid  string
+	}
+
+	// CommitGraph answers which refs contain a commit without running `git branch --contains` for each commit.
+	// Only the commits that are not reachable from the boundary refs (e.g. the default branch) are loaded,
+	// which keeps the graph small even in repositories with a long history.
+	CommitGraph struct {
+		refs       []Ref
+		boundary   []Ref
+		parents    map[string][]string
+		containing map[string][]uint64
+	}
+)
+
+var refPrefixRegex = regexp.MustCompile(`^refs/(?:heads|remotes/.+?)/`)
+
+func (r Ref) BranchName() string {
+	return refPrefixRegex.ReplaceAllString(r.Name, "")
+}
+
+// The commits are pairs of an oid and its parents, ordered so that children come before their parents
+// as printed by `git rev-list --topo-order --parents`.
+func NewCommitGraph(refs []Ref, boundary []Ref, commits [][]string) *CommitGraph {
+	words := (len(refs) + 63) / 64
+	parents := make(map[string][]string, len(commits))
+	containing := make(map[string][]uint64, len(commits))
+	for _, commit := range commits {
+		parents[commit[0]] = commit[1:]
+		containing[commit[0]] = make([]uint64, words)
+	}
+
+	for i, ref := range refs {
+		if bits, ok := containing[ref.Oid]; ok {
+			bits[i/64] |= 1 << (i % 64)
+		}
+	}
+
+	// Every child is visited before its parents, so a commit has received the refs of all its children
+	// by the time its own refs are propagated.
+	for _, commit := range commits {
+		bits := containing[commit[0]]
+		for _, parent := range commit[1:] {
+			if parentBits, ok := containing[parent]; ok {
+				for w := range parentBits {
+					parentBits[w] |= bits[w]
+				}
+			}
+		}
+	}
+
+	return &CommitGraph{
+		refs:       refs,
+		boundary:   boundary,
+		parents:    parents,
+		containing: containing,
+	}
+}
+
+// Returns the refs that contain the commit.
+// Commits outside the graph are reachable from the boundary refs, so only the boundary refs are returned for them.
+func (g *CommitGraph) ContainingRefs(oid string) []Ref {
+	bits, ok := g.containing[oid]
+	if !ok {
+		return g.boundary
+	}
+
+	results := []Ref{}
+	for i, ref := range g.refs {
+		if bits[i/64]&(1<<(i%64)) != 0 {
+			results = append(results, ref)
+		}
+	}
+	return results
+}
+
+// Returns the number of commits reachable from the ref that are not reachable from the boundary refs,
+// i.e. the distance from the ref to its merge base with the boundary.
+func (g *CommitGraph) CountExclusiveCommits(refName string) (int, bool) {
+	idx := slices.IndexFunc(g.refs, func(ref Ref) bool { return ref.Name == refName })
+	if idx < 0 {
+		return 0, false
+	}
+
+	visited := map[string]bool{}
+	queue := []string{g.refs[idx].Oid}
+	for len(queue) > 0 {
+		oid := queue[0]
+		queue = queue[1:]
+		parents, ok := g.parents[oid]
+		if !ok || visited[oid] {
+			continue
+		}
+		visited[oid] = true
+		queue = append(queue, parents...)
+	}
+	return len(visited), true
+}
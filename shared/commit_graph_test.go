@@ -0,0 +1,74 @@
+package shared
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_BranchName(t *testing.T) {
+	assert.Equal(t, "issue1", Ref{Name: "refs/heads/issue1"}.BranchName())
+	assert.Equal(t, "fork/main", Ref{Name: "refs/heads/fork/main"}.BranchName())
+	assert.Equal(t, "main", Ref{Name: "refs/remotes/origin/main"}.BranchName())
+}
+
+/*
+// main   : A---B
+//             \
+// issue1 :     C---D
+//                   \
+// issue2 :           E
+*/
+func Test_ContainingRefs(t *testing.T) {
+	main := Ref{Name: "refs/heads/main", Oid: "B"}
+	issue1 := Ref{Name: "refs/heads/issue1", Oid: "D"}
+	issue2 := Ref{Name: "refs/heads/issue2", Oid: "E"}
+	originIssue1 := Ref{Name: "refs/remotes/origin/issue1", Oid: "C"}
+	graph := NewCommitGraph(
+		[]Ref{issue1, issue2, main, originIssue1},
+		[]Ref{main},
+		[][]string{{"E", "D"}, {"D", "C"}, {"C", "B"}},
+	)
+
+	assert.Equal(t, []Ref{issue2}, graph.ContainingRefs("E"))
+	assert.Equal(t, []Ref{issue1, issue2}, graph.ContainingRefs("D"))
+	assert.Equal(t, []Ref{issue1, issue2, originIssue1}, graph.ContainingRefs("C"))
+	assert.Equal(t, []Ref{main}, graph.ContainingRefs("B"))
+	assert.Equal(t, []Ref{main}, graph.ContainingRefs("A"))
+}
+
+func Test_CountExclusiveCommits(t *testing.T) {
+	main := Ref{Name: "refs/heads/main", Oid: "B"}
+	issue1 := Ref{Name: "refs/heads/issue1", Oid: "E"}
+	issue2 := Ref{Name: "refs/heads/issue2", Oid: "A"}
+	// main   : A---B
+	//           \   \
+	// issue1 :   C---E
+	graph := NewCommitGraph(
+		[]Ref{issue1, issue2, main},
+		[]Ref{main},
+		[][]string{{"E", "C", "B"}, {"C", "A"}},
+	)
+
+	count, ok := graph.CountExclusiveCommits("refs/heads/issue1")
+	assert.True(t, ok)
+	assert.Equal(t, 2, count)
+
+	count, ok = graph.CountExclusiveCommits("refs/heads/issue2")
+	assert.True(t, ok)
+	assert.Equal(t, 0, count)
+
+	_, ok = graph.CountExclusiveCommits("refs/heads/unknown")
+	assert.False(t, ok)
+}
+
+func Test_ContainingRefsWithManyRefs(t *testing.T) {
+	refs := []Ref{}
+	for i := range 100 {
+		refs = append(refs, Ref{Name: fmt.Sprintf("refs/heads/issue%d", i), Oid: "B"})
+	}
+	graph := NewCommitGraph(refs, []Ref{}, [][]string{{"B", "A"}, {"A"}})
+
+	assert.Equal(t, refs, graph.ContainingRefs("A"))
+}
@@ -6,19 +6,32 @@ import "context"
 type Connection interface {
 	GetRemoteNames(ctx context.Context) (string, error)
 	GetSshConfig(ctx context.Context, name string) (string, error)
-	GetRepoNames(ctx context.Context, hostname string, repoName string) (string, error)
+	GetRepoNames(ctx context.Context, hostname string, repoName string) (Repository, error)
 	GetBranchNames(ctx context.Context) (string, error)
 	GetMergedBranchNames(ctx context.Context, remoteName string, branchName string) (string, error)
-	GetLog(ctx context.Context, branchName string) (string, error)
-	GetAssociatedRefNames(ctx context.Context, oid string) (string, error)
-	GetPullRequests(ctx context.Context, hostname string, orgs string, repos string, queryHashes string) (string, error)
+	GetLog(ctx context.Context, branchName string, maxCount int) (string, error)
+	GetRefs(ctx context.Context) (string, error)
+	GetCommitGraph(ctx context.Context, excludedRefNames []string) (string, error)
+	GetTree(ctx context.Context, branchName string) (string, error)
+	GetPatchIds(ctx context.Context, branchName string, excludedRefName string) (string, error)
+	GetCommitPatchIds(ctx context.Context, oids []string) (string, error)
+	GetBranchProtections(ctx context.Context, hostname string, repoName string) ([]BranchProtection, error)
+	GetPullRequests(ctx context.Context, hostname string, orgs string, repos string, queryHashes string) ([]PullRequest, error)
 	GetUncommittedChanges(ctx context.Context, opts ...string) (string, error)
+	GetSubmodules(ctx context.Context, opts ...string) (string, error)
+	GetStashes(ctx context.Context) (string, error)
+	GetReverts(ctx context.Context, refName string) (string, error)
+	GetOperations(ctx context.Context, worktreePath string) (string, error)
 	GetConfig(ctx context.Context, key string) (string, error)
 	AddConfig(ctx context.Context, key string, value string) (string, error)
 	RemoveConfig(ctx context.Context, key string) (string, error)
 	FetchBranch(ctx context.Context, remoteName string, branchName string) (string, error)
 	CheckoutBranch(ctx context.Context, branchName string, detach bool) (string, error)
+	CreateBranch(ctx context.Context, branchName string, startPoint string) (string, error)
 	DeleteBranches(ctx context.Context, branchNames []string) (string, error)
+	RebaseBranch(ctx context.Context, branchName string, upstream string, onto string) (string, error)
+	AbortRebase(ctx context.Context) (string, error)
+	SetUpstream(ctx context.Context, branchName string, upstream string) (string, error)
 	GetWorktrees(ctx context.Context) (string, error)
 	RemoveWorktree(ctx context.Context, path string) (string, error)
 }
@@ -0,0 +1,8 @@
+package shared
+
+// An operation such as a rebase or a merge that was stopped and waits to be continued or aborted.
+type Operation struct {
+	Kind string
+	// The branch the operation was started on, if it is recorded (e.g. the branch being rebased).
+	BranchName string
+}
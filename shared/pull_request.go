@@ -4,13 +4,30 @@ type (
 	PullRequestState int
 
 	PullRequest struct {
-		Name    string
-		State   PullRequestState
-		IsDraft bool
-		Number  int
-		Commits []string
-		Url     string
-		Author  string
+		Name         string
+		State        PullRequestState
+		IsDraft      bool
+		Number       int
+		Commits      []string
+		Url          string
+		Author       string
+		HeadRepoName string
+		HeadTreeOid  string
+		BaseRefName  string
+		BaseRepoName string
+		Labels       []string
+		// The commit the PR was merged as on the base branch (the merge, squash, or last rebased commit)
+		MergeCommitOid string
+		// How the local branch was found to contain the PR when its latest commit is not one of the PR commits,
+		// either "tree" or "patch-id".
+		MatchedBy string
+		// Whether the merge was reverted on the default branch afterwards
+		IsReverted bool
+		// Whether the PR was merged into a branch other than the default branch and the integration branches,
+		// so its changes may never have reached them
+		IsMergedElsewhere bool
+		// The label that keeps the branch after the PR is merged or closed (e.g. `backport-pending`), if any
+		KeepLabel string
 	}
 )
 
@@ -0,0 +1,12 @@
+package shared
+
+type Repository struct {
+	Owner             string
+	Name              string
+	DefaultBranchName string
+	Parent            *Repository
+}
+
+func (r Repository) NameWithOwner() string {
+	return r.Owner + "/" + r.Name
+}
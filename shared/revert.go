@@ -0,0 +1,12 @@
+package shared
+
+// A commit that reverts other commits, or re-lands them by reverting a revert.
+type Revert struct {
+	Oid string
+	// The commits named by `This reverts commit <oid>.`
+	RevertedOids []string
+	// The PRs named in the reverted subject (e.g. `Revert "Add a.txt (#1)"`) or by a GitHub revert PR (`Reverts owner/repo#1`)
+	RevertedNumbers []int
+	// Whether the commit reverts a revert (e.g. `Reapply "Add a.txt (#1)"`), which brings the PRs back
+	IsReapply bool
+}
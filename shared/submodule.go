@@ -0,0 +1,6 @@
+package shared
+
+type Submodule struct {
+	Path          string
+	IsInitialized bool
+}
@@ -6,4 +6,5 @@ type Worktree struct {
 	IsMain     bool
 	IsLocked   bool
 	IsPrunable bool
+	IsBare     bool
 }